@@ -0,0 +1,58 @@
+package memlog
+
+import "fmt"
+
+// chunk splits r into chunks of at most the maximum record size. The first
+// chunk carries the metadata of r, all chunks carry the creation and expiry
+// time of r.
+func (l *Log) chunk(r Record) []Record {
+	size := l.conf.maxRecordSize
+	n := (len(r.Data) + size - 1) / size
+
+	chunks := make([]Record, n)
+	for i := range chunks {
+		end := (i + 1) * size
+		if end > len(r.Data) {
+			end = len(r.Data)
+		}
+
+		h := Header{
+			Created: r.Metadata.Created,
+			Extensions: &Extensions{
+				Expires: r.Metadata.ext().Expires,
+				Chunk:   i,
+				Chunks:  n,
+			},
+		}
+		if i == 0 {
+			h = r.Metadata
+			h.extend().Chunks = n
+		}
+
+		chunks[i] = Record{
+			Metadata: h,
+			Data:     r.Data[i*size : end],
+		}
+	}
+
+	return chunks
+}
+
+// assemble returns the record written in chunks starting with first, reading
+// the remaining chunks with next
+func assemble(first Record, next func(offset Offset) (Record, error)) (Record, error) {
+	chunks := first.Metadata.ext().Chunks
+	data := make([]byte, 0, len(first.Data)*chunks)
+	data = append(data, first.Data...)
+
+	for i := 1; i < chunks; i++ {
+		c, err := next(first.Metadata.Offset + Offset(i))
+		if err != nil {
+			return Record{}, fmt.Errorf("read chunk %d of record at offset %d: %w", i, first.Metadata.Offset, err)
+		}
+		data = append(data, c.Data...)
+	}
+
+	first.Data = data
+	return first, nil
+}
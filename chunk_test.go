@@ -0,0 +1,105 @@
+package memlog_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestLog_WithChunking(t *testing.T) {
+	large := bytes.Repeat([]byte("abcd"), 5) // 20 bytes, 3 chunks
+
+	newLog := func(t *testing.T, opts ...memlog.Option) *memlog.Log {
+		opts = append([]memlog.Option{
+			memlog.WithMaxRecordDataSize(8),
+			memlog.WithMaxSegmentSize(4),
+			memlog.WithChunking(),
+		}, opts...)
+
+		l, err := memlog.New(context.Background(), opts...)
+		assert.NilError(t, err)
+		return l
+	}
+
+	t.Run("reassembles chunked record on read", func(t *testing.T) {
+		ctx := context.Background()
+		l := newLog(t, memlog.WithChecksumVerification())
+
+		_, err := l.Write(ctx, []byte("small"))
+		assert.NilError(t, err)
+
+		offset, err := l.Write(ctx, large)
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(1))
+
+		r, err := l.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, large)
+		assert.Equal(t, r.Metadata.Extensions.Chunks, 3)
+
+		_, err = l.Read(ctx, offset+1)
+		assert.Assert(t, errors.Is(err, memlog.ErrChunkContinuation))
+
+		// next record follows the chunks
+		offset, err = l.Write(ctx, []byte("small"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(4))
+
+		v, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+		r, err = v.Read(ctx, 1)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, large)
+
+		assert.NilError(t, l.Validate())
+	})
+
+	t.Run("batch reads and streams skip continuation chunks", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		l := newLog(t)
+
+		for _, d := range [][]byte{large, []byte("small")} {
+			_, err := l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		batch := make([]memlog.Record, 4)
+		count, err := l.ReadBatch(ctx, 0, batch)
+		assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+		assert.Equal(t, count, 2)
+		assert.DeepEqual(t, batch[0].Data, large)
+		assert.DeepEqual(t, batch[1].Data, []byte("small"))
+
+		stream := l.Stream(ctx, 0)
+		r, ok := stream.Next()
+		assert.Assert(t, ok)
+		assert.DeepEqual(t, r.Data, large)
+
+		r, ok = stream.Next()
+		assert.Assert(t, ok)
+		assert.Equal(t, r.Metadata.Offset, memlog.Offset(3))
+	})
+
+	t.Run("fails with more chunks than segment size", func(t *testing.T) {
+		ctx := context.Background()
+		l := newLog(t)
+
+		_, err := l.Write(ctx, bytes.Repeat([]byte("a"), 33))
+		assert.ErrorIs(t, err, memlog.ErrRecordTooLarge)
+	})
+
+	t.Run("fails without chunking", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxRecordDataSize(8))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, large)
+		assert.ErrorIs(t, err, memlog.ErrRecordTooLarge)
+	})
+}
@@ -0,0 +1,130 @@
+package memlog
+
+import "context"
+
+// compactor tracks the latest offset of each record key for compaction
+type compactor struct {
+	latest map[string]Offset
+}
+
+func newCompactor() *compactor {
+	return &compactor{
+		latest: make(map[string]Offset),
+	}
+}
+
+func (c *compactor) add(key []byte, offset Offset) {
+	if len(key) == 0 {
+		return
+	}
+	c.latest[string(key)] = offset
+}
+
+// superseded returns true if a newer record with the same key as r was written
+func (c *compactor) superseded(r Record) bool {
+	if len(r.Metadata.Key) == 0 {
+		return false
+	}
+
+	offset, ok := c.latest[string(r.Metadata.Key)]
+	return ok && offset != r.Metadata.Offset
+}
+
+// evict removes the keys of all records in the purged segment s unless they
+// have been written again with a newer offset
+func (c *compactor) evict(s *segment) {
+	records, err := s.records()
+	if err != nil {
+		return
+	}
+
+	for _, r := range records {
+		if len(r.Metadata.Key) == 0 {
+			continue
+		}
+
+		key := string(r.Metadata.Key)
+		if offset, ok := c.latest[key]; ok && offset == r.Metadata.Offset {
+			delete(c.latest, key)
+		}
+	}
+}
+
+// WriteKey creates a new record in the log with the provided key and data. The
+// key is stored in the record metadata and used for compaction, see
+// WithCompaction(). Otherwise WriteKey behaves like Write.
+//
+// Safe for concurrent use.
+func (l *Log) WriteKey(ctx context.Context, key, data []byte) (Offset, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offset, _, err := l.tryWrite(ctx, writeOptions{key: key}, data)
+	return offset, err
+}
+
+// Compact removes all superseded records from the history segments of the log,
+// see WithCompaction(). Compaction is otherwise only performed when a segment
+// is sealed. If compaction is not enabled, Compact does nothing.
+//
+// Safe for concurrent use.
+func (l *Log) Compact(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.compactor != nil {
+		l.compact()
+	}
+	return nil
+}
+
+// compact removes superseded records from all history segments. Removed records
+// are replaced with records without key and data, preserving the offset and
+// metadata. The records of a compacted segment are copied instead of modified
+// in place as they might be shared with views of the log, see Snapshot(). Must
+// be protected with a lock by the caller.
+func (l *Log) compact() {
+	for _, s := range l.history {
+		store, ok := s.store.(*sliceStore)
+		if !ok {
+			continue
+		}
+
+		var compacted []Record
+		for i, r := range store.records {
+			if !l.compactor.superseded(r) {
+				continue
+			}
+
+			if compacted == nil {
+				compacted = make([]Record, len(store.records), s.size)
+				copy(compacted, store.records)
+			}
+
+			h := Header{
+				Offset:  r.Metadata.Offset,
+				Created: r.Metadata.Created,
+				Seq:     r.Metadata.Seq,
+			}
+			if expires := r.Metadata.ext().Expires; expires != nil {
+				h.Extensions = &Extensions{Expires: expires}
+			}
+			compacted[i] = Record{Metadata: h}
+			s.bytes -= len(r.Data)
+			s.count--
+			l.bytes -= len(r.Data)
+			l.records--
+			if r.Metadata.Offset < l.floor {
+				l.truncated--
+			}
+		}
+
+		if compacted != nil {
+			store.records = compacted
+		}
+	}
+}
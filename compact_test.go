@@ -0,0 +1,129 @@
+package memlog_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestLog_WithCompaction(t *testing.T) {
+	writeKeys := func(t *testing.T, l *memlog.Log, keys ...string) {
+		t.Helper()
+		for _, key := range keys {
+			_, err := l.WriteKey(context.Background(), []byte(key), []byte("data-"+key))
+			assert.NilError(t, err)
+		}
+	}
+
+	t.Run("compacts history segments when a segment is sealed", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx,
+			memlog.WithMaxSegmentSize(2),
+			memlog.WithMaxHistorySegments(2),
+			memlog.WithCompaction(),
+		)
+		assert.NilError(t, err)
+
+		// seals segment [2-3] on write of offset 4
+		writeKeys(t, l, "a", "b", "a", "c", "d")
+
+		_, err = l.Read(ctx, 0)
+		assert.Assert(t, errors.Is(err, memlog.ErrCompacted))
+
+		r, err := l.Read(ctx, 2)
+		assert.NilError(t, err)
+		assert.Equal(t, string(r.Metadata.Key), "a")
+		assert.Equal(t, string(r.Data), "data-a")
+
+		batch := make([]memlog.Record, 10)
+		count, err := l.ReadBatch(ctx, 0, batch)
+		assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+		assert.Equal(t, count, 4)
+
+		var keys []string
+		for _, r := range batch[:count] {
+			keys = append(keys, string(r.Metadata.Key))
+		}
+		assert.DeepEqual(t, keys, []string{"b", "a", "c", "d"})
+
+		assert.Equal(t, l.Stats(ctx).Bytes, 4*len("data-a"))
+		assert.NilError(t, l.Validate())
+	})
+
+	t.Run("compacts on demand", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2), memlog.WithCompaction())
+		assert.NilError(t, err)
+
+		writeKeys(t, l, "a", "b", "a")
+
+		_, err = l.Read(ctx, 0)
+		assert.NilError(t, err)
+
+		assert.NilError(t, l.Compact(ctx))
+		_, err = l.Read(ctx, 0)
+		assert.Assert(t, errors.Is(err, memlog.ErrCompacted))
+	})
+
+	t.Run("retains records without key", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2), memlog.WithCompaction())
+		assert.NilError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err = l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+		}
+
+		assert.NilError(t, l.Compact(ctx))
+		r, err := l.Read(ctx, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, string(r.Data), "data")
+	})
+
+	t.Run("streams skip compacted records", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2), memlog.WithCompaction())
+		assert.NilError(t, err)
+
+		writeKeys(t, l, "a", "a", "b")
+		assert.NilError(t, l.Compact(ctx))
+
+		stream := l.Stream(ctx, 0)
+		r, ok := stream.Next()
+		assert.Assert(t, ok)
+		assert.Equal(t, r.Metadata.Offset, memlog.Offset(1))
+	})
+
+	t.Run("snapshots are not modified by compaction", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2), memlog.WithCompaction())
+		assert.NilError(t, err)
+
+		writeKeys(t, l, "a", "b", "a")
+
+		view, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+
+		assert.NilError(t, l.Compact(ctx))
+
+		r, err := view.Read(ctx, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, string(r.Data), "data-a")
+	})
+
+	t.Run("fails with custom segment store", func(t *testing.T) {
+		newStore := func(size int) memlog.SegmentStore {
+			return &fakeStore{size: size}
+		}
+
+		_, err := memlog.New(context.Background(), memlog.WithCompaction(), memlog.WithSegmentStore(newStore))
+		assert.ErrorContains(t, err, "compaction requires the default segment store")
+	})
+}
@@ -0,0 +1,70 @@
+package memlog
+
+import (
+	"context"
+	"sync"
+)
+
+// Cursor tracks the read position of a consumer in a log. Reading with Next
+// advances the cursor and commits the read offset in one step, providing
+// at-most-once delivery: a record is not delivered again even if the consumer
+// fails before processing it. Consumers persist the committed offset, see
+// Committed(), to resume after a restart.
+//
+// Safe for concurrent use.
+type Cursor struct {
+	log *Log
+
+	mu        sync.Mutex
+	position  Offset // next offset to read
+	committed Offset // last committed offset
+}
+
+// Cursor returns a new cursor positioned at the specified offset. No offset is
+// committed until the first successful call to Next.
+func (l *Log) Cursor(start Offset) *Cursor {
+	return &Cursor{
+		log:       l,
+		position:  start,
+		committed: InvalidOffset,
+	}
+}
+
+// Next reads the record at the position of the cursor, advances the cursor and
+// commits the record offset. Records which are not readable, e.g. compacted or
+// expired records, are skipped, advancing the position but not the committed
+// offset. If an error occurs, e.g. ErrFutureOffset at the end of the log or
+// ErrOutOfRange if the position was purged, an invalid (empty) record and the
+// error is returned and the cursor is not modified otherwise.
+func (c *Cursor) Next(ctx context.Context) (Record, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, err := c.log.Read(ctx, c.position)
+	for skippable(err) {
+		c.position++
+		r, err = c.log.Read(ctx, c.position)
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	c.committed = r.Metadata.Offset
+	c.position = r.Metadata.Offset + 1
+	return r, nil
+}
+
+// Position returns the offset of the next record read with Next
+func (c *Cursor) Position() Offset {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.position
+}
+
+// Committed returns the last committed offset. If no offset has been
+// committed, InvalidOffset is returned.
+func (c *Cursor) Committed() Offset {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.committed
+}
@@ -0,0 +1,110 @@
+package memlog_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestCursor_Next(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	c := l.Cursor(0)
+	assert.Equal(t, c.Committed(), memlog.InvalidOffset)
+
+	t.Run("end of log does not advance cursor", func(t *testing.T) {
+		_, err = c.Next(ctx)
+		assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+		assert.Equal(t, c.Position(), memlog.Offset(0))
+		assert.Equal(t, c.Committed(), memlog.InvalidOffset)
+	})
+
+	data := memlog.NewTestDataSlice(t, 10)
+	for _, d := range data {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	t.Run("reads, advances and commits", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			r, err := c.Next(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Offset, memlog.Offset(i))
+			assert.DeepEqual(t, r.Data, data[i])
+			assert.Equal(t, c.Committed(), memlog.Offset(i))
+			assert.Equal(t, c.Position(), memlog.Offset(i+1))
+		}
+	})
+
+	t.Run("concurrent consumers receive each record once", func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			seen = make(map[memlog.Offset]int)
+			wg   sync.WaitGroup
+		)
+
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					r, err := c.Next(ctx)
+					if err != nil {
+						return
+					}
+
+					mu.Lock()
+					seen[r.Metadata.Offset]++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, len(seen), 5)
+		for offset, count := range seen {
+			assert.Equal(t, count, 1, "offset %d", offset)
+		}
+		assert.Equal(t, c.Committed(), memlog.Offset(9))
+	})
+
+	t.Run("purged position does not advance cursor", func(t *testing.T) {
+		c := l.Cursor(0)
+
+		// purges offsets [0-9]
+		for _, d := range memlog.NewTestDataSlice(t, 11) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		_, err = c.Next(ctx)
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+		assert.Equal(t, c.Position(), memlog.Offset(0))
+	})
+
+	t.Run("skips compacted records", func(t *testing.T) {
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2), memlog.WithCompaction())
+		assert.NilError(t, err)
+
+		// offset 0 is superseded
+		for _, key := range []string{"a", "b", "a", "c"} {
+			_, err = l.WriteKey(ctx, []byte(key), []byte("data-"+key))
+			assert.NilError(t, err)
+		}
+		assert.NilError(t, l.Compact(ctx))
+
+		c := l.Cursor(0)
+		r, err := c.Next(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, r.Metadata.Offset, memlog.Offset(1))
+		assert.Equal(t, c.Committed(), memlog.Offset(1))
+		assert.Equal(t, c.Position(), memlog.Offset(2))
+	})
+}
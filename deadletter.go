@@ -0,0 +1,49 @@
+package memlog
+
+import (
+	"time"
+)
+
+// deadLetters is a bounded ring of rejected writes. Not safe for concurrent
+// use.
+type deadLetters struct {
+	records []Record
+	next    int // ring position of the next record if full
+}
+
+func newDeadLetters(capacity int) *deadLetters {
+	return &deadLetters{
+		records: make([]Record, 0, capacity),
+	}
+}
+
+// add appends a copy of data, replacing the oldest record if the ring is full
+func (d *deadLetters) add(data []byte, created time.Time) {
+	dCopy := make([]byte, len(data))
+	copy(dCopy, data)
+	r := Record{
+		Metadata: Header{
+			Offset:  InvalidOffset,
+			Created: created,
+		},
+		Data: dCopy,
+	}
+
+	if len(d.records) < cap(d.records) {
+		d.records = append(d.records, r)
+		return
+	}
+
+	d.records[d.next] = r
+	d.next = (d.next + 1) % len(d.records)
+}
+
+// list returns copies of all records, oldest first
+func (d *deadLetters) list() []Record {
+	records := make([]Record, 0, len(d.records))
+	for i := 0; i < len(d.records); i++ {
+		r := d.records[(d.next+i)%len(d.records)]
+		records = append(records, r.WithData(r.Data))
+	}
+	return records
+}
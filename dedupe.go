@@ -0,0 +1,137 @@
+package memlog
+
+import (
+	"context"
+	"errors"
+)
+
+// DedupeKeyFunc returns the idempotency key of the specified record data.
+// Records with equal keys are considered the same logical record.
+type DedupeKeyFunc func(data []byte) []byte
+
+// deduper tracks the idempotency keys of the records retained in the log. Not
+// safe for concurrent use.
+type deduper struct {
+	keyFn DedupeKeyFunc
+	keys  map[string]Offset
+}
+
+func newDeduper(fn DedupeKeyFunc) *deduper {
+	return &deduper{
+		keyFn: fn,
+		keys:  make(map[string]Offset),
+	}
+}
+
+// lookup returns the offset of a retained record with the same key as data
+func (d *deduper) lookup(data []byte) (Offset, bool) {
+	offset, ok := d.keys[string(d.keyFn(data))]
+	return offset, ok
+}
+
+func (d *deduper) add(data []byte, offset Offset) {
+	d.keys[string(d.keyFn(data))] = offset
+}
+
+// evict removes the keys of all records in the purged segment s unless they
+// have been written again with a newer offset
+func (d *deduper) evict(s *segment) {
+	records, err := s.records()
+	if err != nil {
+		// keys are retained until overwritten
+		return
+	}
+
+	for _, r := range records {
+		// unwritten reserved offset
+		if len(r.Data) == 0 {
+			continue
+		}
+
+		key := string(d.keyFn(r.Data))
+		if offset, ok := d.keys[key]; ok && offset == r.Metadata.Offset {
+			delete(d.keys, key)
+		}
+	}
+}
+
+// evictBefore removes the keys of all records before offset, e.g. after the
+// log was truncated
+func (d *deduper) evictBefore(offset Offset) {
+	for key, o := range d.keys {
+		if o < offset {
+			delete(d.keys, key)
+		}
+	}
+}
+
+// WriteWithID creates a new record in the log with the provided idempotency
+// key and data, e.g. for producers retrying writes. If a record with the same
+// id is retained in the log and was written within the window configured with
+// WithDedupeWindow(), the record is not written and the offset of the existing
+// record is returned instead. The id is stored in the record metadata. id must
+// not be empty. Otherwise WriteWithID behaves like Write.
+//
+// Safe for concurrent use.
+func (l *Log) WriteWithID(ctx context.Context, id string, data []byte) (Offset, error) {
+	if id == "" {
+		return InvalidOffset, errors.New("id must not be empty")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offset, _, err := l.tryWrite(ctx, writeOptions{id: id}, data)
+	return offset, err
+}
+
+// addID tracks the offset of a record written with id. Must be protected with a
+// lock by the caller.
+func (l *Log) addID(id string, offset Offset) {
+	if l.ids == nil {
+		l.ids = make(map[string]Offset)
+	}
+	l.ids[id] = offset
+}
+
+// evictIDs removes the ids of all records in the purged segment s unless they
+// have been written again with a newer offset. Must be protected with a lock by
+// the caller.
+func (l *Log) evictIDs(s *segment) {
+	for id, offset := range l.ids {
+		if offset >= s.start && offset <= s.currentOffset() {
+			delete(l.ids, id)
+		}
+	}
+}
+
+// evictIDsBefore removes the ids of all records before offset, e.g. after the
+// log was truncated. Must be protected with a lock by the caller.
+func (l *Log) evictIDsBefore(offset Offset) {
+	for id, o := range l.ids {
+		if o < offset {
+			delete(l.ids, id)
+		}
+	}
+}
+
+// inDedupeWindow returns true if the record at offset was written within the
+// dedupe window, i.e. a write with the same idempotency key is a duplicate. Must
+// be protected with a lock by the caller.
+func (l *Log) inDedupeWindow(offset Offset) bool {
+	if l.conf.dedupeWindow == 0 {
+		return true
+	}
+
+	s, err := l.getSegment(offset)
+	if err != nil {
+		return false
+	}
+
+	r, err := s.read(context.Background(), offset)
+	if err != nil {
+		return false
+	}
+
+	return l.clock.Now().Sub(r.Metadata.Created) < l.conf.dedupeWindow
+}
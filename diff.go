@@ -0,0 +1,130 @@
+package memlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// diffBatchSize is the number of records read at once from each log by Diff
+const diffBatchSize = 64
+
+// Diff compares the data of the records in logs a and b over their common
+// offset range, i.e. from the greater of the earliest offsets to the lesser of
+// the latest offsets, and returns the first offset where the record data
+// differs, e.g. to debug diverged replicas. If the records are identical over
+// the common range or the logs have no offsets in common, e.g. if one of the
+// logs is empty, InvalidOffset is returned. Records outside the common range
+// are not compared. Records which are skipped by reads, e.g. compacted or
+// expired records, are not compared. If a record is readable in only one of the
+// logs, its offset is returned.
+//
+// The offset ranges of both logs are captured when Diff is called. If records
+// in the common range are purged during the comparison, InvalidOffset and the
+// error is returned.
+//
+// Safe for concurrent use.
+func Diff(ctx context.Context, a, b *Log) (Offset, error) {
+	if a == nil || b == nil {
+		return InvalidOffset, errors.New("logs must not be nil")
+	}
+
+	earliestA, latestA := a.Range(ctx)
+	earliestB, latestB := b.Range(ctx)
+	if earliestA == InvalidOffset || earliestB == InvalidOffset {
+		return InvalidOffset, nil
+	}
+
+	from, to := earliestA, latestA
+	if earliestB > from {
+		from = earliestB
+	}
+	if latestB < to {
+		to = latestB
+	}
+
+	batchA := make([]Record, diffBatchSize)
+	batchB := make([]Record, diffBatchSize)
+	for from <= to {
+		size := diffBatchSize
+		if remaining := int(to - from + 1); remaining < size {
+			size = remaining
+		}
+
+		recordsA, lastA, err := diffBatch(ctx, a, from, to, batchA[:size])
+		if err != nil {
+			return InvalidOffset, fmt.Errorf("read offset %d from first log: %w", from, err)
+		}
+
+		recordsB, lastB, err := diffBatch(ctx, b, from, to, batchB[:size])
+		if err != nil {
+			return InvalidOffset, fmt.Errorf("read offset %d from second log: %w", from, err)
+		}
+
+		// batch reads might be limited, see WithMaxReadBatch, i.e. only compare
+		// up to the offset read from both logs
+		last := lastA
+		if lastB < last {
+			last = lastB
+		}
+
+		// records are read in offset order but skipped records, e.g. compacted
+		// or expired, are not returned
+		i, j := 0, 0
+		for {
+			offsetA, offsetB := offsetAt(recordsA, i, last), offsetAt(recordsB, j, last)
+			if offsetA == InvalidOffset && offsetB == InvalidOffset {
+				break
+			}
+
+			// record only readable in one of the logs
+			if offsetA != offsetB {
+				if offsetA == InvalidOffset || (offsetB != InvalidOffset && offsetB < offsetA) {
+					return offsetB, nil
+				}
+				return offsetA, nil
+			}
+
+			if !bytes.Equal(recordsA[i].Data, recordsB[j].Data) {
+				return offsetA, nil
+			}
+			i++
+			j++
+		}
+
+		from = last + 1
+	}
+
+	return InvalidOffset, nil
+}
+
+// diffBatch reads records from l starting at offset from into batch and
+// returns the records with an offset up to to and the last offset covered by
+// the read. Skipped records, e.g. compacted or expired, are not returned.
+func diffBatch(ctx context.Context, l *Log, from, to Offset, batch []Record) ([]Record, Offset, error) {
+	count, err := l.ReadBatch(ctx, from, batch)
+	if err != nil && !isEndOfLog(err) {
+		return nil, InvalidOffset, err
+	}
+
+	// no more readable records, e.g. all remaining records are skipped
+	if err != nil || count == 0 {
+		return batch[:count], to, nil
+	}
+
+	last := batch[count-1].Metadata.Offset
+	if last > to {
+		last = to
+	}
+	return batch[:count], last, nil
+}
+
+// offsetAt returns the offset of records[i] or InvalidOffset if i is out of
+// bounds or the offset is greater than last
+func offsetAt(records []Record, i int, last Offset) Offset {
+	if i >= len(records) || records[i].Metadata.Offset > last {
+		return InvalidOffset
+	}
+	return records[i].Metadata.Offset
+}
@@ -0,0 +1,187 @@
+package memlog_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	data := memlog.NewTestDataSlice(t, 200)
+
+	newLog := func(start memlog.Offset, records [][]byte, opts ...memlog.Option) *memlog.Log {
+		opts = append([]memlog.Option{memlog.WithStartOffset(start), memlog.WithMaxSegmentSize(100)}, opts...)
+		l, err := memlog.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		for _, d := range records {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+		return l
+	}
+
+	t.Run("fails with nil log", func(t *testing.T) {
+		_, err := memlog.Diff(ctx, nil, newLog(0, nil))
+		assert.ErrorContains(t, err, "must not be nil")
+	})
+
+	testCases := []struct {
+		name string
+		a    *memlog.Log
+		b    *memlog.Log
+		want memlog.Offset
+	}{
+		{
+			name: "empty logs",
+			a:    newLog(0, nil),
+			b:    newLog(0, nil),
+			want: memlog.InvalidOffset,
+		},
+		{
+			name: "one empty log",
+			a:    newLog(0, data),
+			b:    newLog(0, nil),
+			want: memlog.InvalidOffset,
+		},
+		{
+			name: "identical logs",
+			a:    newLog(0, data),
+			b:    newLog(0, data),
+			want: memlog.InvalidOffset,
+		},
+		{
+			name: "identical over common range with different lengths",
+			a:    newLog(0, data),
+			b:    newLog(0, data[:150]),
+			want: memlog.InvalidOffset,
+		},
+		{
+			name: "diverged logs",
+			a:    newLog(0, data),
+			b:    newLog(0, append(append(data[:120:120], []byte("diverged")), data[121:]...)),
+			want: 120,
+		},
+		{
+			name: "diverged logs with different start offsets",
+			a:    newLog(0, data[:150]),
+			b:    newLog(50, append(data[50:130:130], []byte("diverged"))),
+			want: 130,
+		},
+		{
+			name: "diverged logs with limited batch reads",
+			a:    newLog(0, data, memlog.WithMaxReadBatch(7)),
+			b:    newLog(0, append(append(data[:120:120], []byte("diverged")), data[121:]...)),
+			want: 120,
+		},
+		{
+			name: "no common range",
+			a:    newLog(0, data[:10]),
+			b:    newLog(20, data[20:30]),
+			want: memlog.InvalidOffset,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := memlog.Diff(ctx, tc.a, tc.b)
+			assert.NilError(t, err)
+			assert.Equal(t, got, tc.want)
+
+			got, err = memlog.Diff(ctx, tc.b, tc.a)
+			assert.NilError(t, err)
+			assert.Equal(t, got, tc.want)
+		})
+	}
+
+	t.Run("compares records by offset with compacted records", func(t *testing.T) {
+		newCompacted := func(values ...string) *memlog.Log {
+			l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(4), memlog.WithMaxHistorySegments(2), memlog.WithCompaction())
+			assert.NilError(t, err)
+
+			for i, v := range values {
+				key := []byte{byte('a' + i%3)}
+				_, err = l.WriteKey(ctx, key, []byte(v))
+				assert.NilError(t, err)
+			}
+			assert.NilError(t, l.Compact(ctx))
+			return l
+		}
+
+		values := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+		diverged := append(append(values[:7:7], "diverged"), values[8:]...)
+
+		a, b := newCompacted(values...), newCompacted(diverged...)
+
+		// offsets [0-6] are compacted
+		_, err := a.Read(ctx, 0)
+		assert.Assert(t, errors.Is(err, memlog.ErrCompacted))
+
+		got, err := memlog.Diff(ctx, a, b)
+		assert.NilError(t, err)
+		assert.Equal(t, got, memlog.Offset(7))
+
+		got, err = memlog.Diff(ctx, a, newCompacted(values...))
+		assert.NilError(t, err)
+		assert.Equal(t, got, memlog.InvalidOffset)
+	})
+
+	t.Run("skipped records at end of log with EOF semantics", func(t *testing.T) {
+		c := clock.NewMock()
+		newLog := func() *memlog.Log {
+			l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithEOFSemantics())
+			assert.NilError(t, err)
+
+			_, err = l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+			_, err = l.WriteTTL(ctx, time.Minute, []byte("ephemeral"))
+			assert.NilError(t, err)
+			return l
+		}
+
+		a, b := newLog(), newLog()
+		c.Add(time.Minute)
+
+		got, err := memlog.Diff(ctx, a, b)
+		assert.NilError(t, err)
+		assert.Equal(t, got, memlog.InvalidOffset)
+	})
+
+	t.Run("record compacted in only one log", func(t *testing.T) {
+		a, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2), memlog.WithCompaction())
+		assert.NilError(t, err)
+		b, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2))
+		assert.NilError(t, err)
+
+		for _, l := range []*memlog.Log{a, b} {
+			for _, key := range []string{"a", "b", "a"} {
+				_, err = l.WriteKey(ctx, []byte(key), []byte("data"))
+				assert.NilError(t, err)
+			}
+		}
+		assert.NilError(t, a.Compact(ctx))
+
+		got, err := memlog.Diff(ctx, a, b)
+		assert.NilError(t, err)
+		assert.Equal(t, got, memlog.Offset(0))
+	})
+
+	t.Run("fails when common range is purged", func(t *testing.T) {
+		l := newLog(0, data, memlog.WithFaultInjector(func(op string, offset memlog.Offset) error {
+			if op == memlog.FaultOpRead && offset == 150 {
+				return memlog.ErrOutOfRange
+			}
+			return nil
+		}))
+
+		_, err := memlog.Diff(ctx, l, newLog(0, data))
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+	})
+}
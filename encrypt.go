@@ -0,0 +1,161 @@
+package memlog
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// encryptionOverhead is the number of bytes added to the data of an encrypted
+// record, i.e. the AES-GCM nonce and tag
+const encryptionOverhead = 12 + 16
+
+// keyring holds the AES-GCM keys for record data encryption by key ID. Safe for
+// concurrent use, as it is shared with views and replicas of the log.
+type keyring struct {
+	mu     sync.RWMutex
+	active string // key ID used to encrypt new records
+	keys   map[string]cipher.AEAD
+}
+
+func newKeyring() *keyring {
+	return &keyring{
+		keys: make(map[string]cipher.AEAD),
+	}
+}
+
+// add adds key with the specified ID to the keyring and uses it to encrypt new
+// records. The ID must not be empty and must not be used by another key.
+func (k *keyring) add(id string, key []byte) error {
+	if id == "" {
+		return errors.New("key ID must not be empty")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("key ID %q already exists", id)
+	}
+
+	k.keys[id] = aead
+	k.active = id
+	return nil
+}
+
+// encrypt returns r with the data encrypted with the active key. The record
+// offset is authenticated, i.e. encrypted data can not be moved to another
+// offset.
+func (k *keyring) encrypt(r Record) (Record, error) {
+	k.mu.RLock()
+	id, aead := k.active, k.keys[k.active]
+	k.mu.RUnlock()
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(r.Data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return Record{}, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	r.Data = aead.Seal(nonce, nonce, r.Data, additionalData(r.Metadata.Offset))
+	r.Metadata.extend().KeyID = id
+	return r, nil
+}
+
+// decrypt returns r with the data decrypted with the key of the record
+func (k *keyring) decrypt(r Record) (Record, error) {
+	k.mu.RLock()
+	id := r.Metadata.ext().KeyID
+	aead, ok := k.keys[id]
+	k.mu.RUnlock()
+
+	if !ok {
+		return Record{}, fmt.Errorf("record at offset %d: unknown encryption key %q", r.Metadata.Offset, id)
+	}
+
+	if len(r.Data) < aead.NonceSize() {
+		return Record{}, fmt.Errorf("record at offset %d: encrypted data too short", r.Metadata.Offset)
+	}
+
+	nonce, data := r.Data[:aead.NonceSize()], r.Data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, data, additionalData(r.Metadata.Offset))
+	if err != nil {
+		return Record{}, fmt.Errorf("record at offset %d: decrypt: %w", r.Metadata.Offset, err)
+	}
+
+	r.Data = plain
+	return r, nil
+}
+
+// additionalData returns the authenticated additional data of the record at
+// offset
+func additionalData(offset Offset) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(offset))
+	return b[:]
+}
+
+// RotateEncryptionKey encrypts all subsequently written records with the
+// specified key, see WithEncryption(). Records encrypted with previous keys
+// remain readable. id must be unique among the keys of the log. key must be a
+// valid AES-128, AES-192 or AES-256 key, i.e. 16, 24 or 32 bytes.
+//
+// Safe for concurrent use.
+func (l *Log) RotateEncryptionKey(id string, key []byte) error {
+	if l.keys == nil {
+		return errors.New("encryption is not enabled")
+	}
+
+	if err := l.keys.add(id, key); err != nil {
+		return fmt.Errorf("invalid encryption key: %v", err)
+	}
+	return nil
+}
+
+// encrypt returns r with encrypted data if encryption is enabled. Must be
+// protected with a lock by the caller.
+func (l *Log) encrypt(r Record) (Record, error) {
+	if l.keys == nil {
+		return r, nil
+	}
+	return l.keys.encrypt(r)
+}
+
+// decrypt returns r with decrypted data using keys if r is encrypted. The data
+// of r is not modified. keys might be nil if encryption is disabled.
+func decrypt(keys *keyring, r Record) (Record, error) {
+	if r.Metadata.ext().KeyID == "" {
+		return r, nil
+	}
+
+	if keys == nil {
+		return Record{}, fmt.Errorf("record at offset %d is encrypted but encryption is not enabled", r.Metadata.Offset)
+	}
+	return keys.decrypt(r)
+}
+
+// decryptAll returns a copy of records with decrypted data
+func (l *Log) decryptAll(records []Record) ([]Record, error) {
+	decrypted := make([]Record, len(records))
+	for i, r := range records {
+		d, err := decrypt(l.keys, r)
+		if err != nil {
+			return nil, err
+		}
+		decrypted[i] = d
+	}
+	return decrypted, nil
+}
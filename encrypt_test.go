@@ -0,0 +1,120 @@
+package memlog_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestLog_WithEncryption(t *testing.T) {
+	key1 := bytes.Repeat([]byte("1"), 32)
+	key2 := bytes.Repeat([]byte("2"), 32)
+
+	t.Run("stores encrypted data and reads plaintext", func(t *testing.T) {
+		ctx := context.Background()
+
+		var store *fakeStore
+		newStore := func(size int) memlog.SegmentStore {
+			store = &fakeStore{size: size}
+			return store
+		}
+
+		l, err := memlog.New(ctx,
+			memlog.WithEncryption("key-1", key1),
+			memlog.WithSegmentStore(newStore),
+			memlog.WithChecksumVerification(),
+		)
+		assert.NilError(t, err)
+
+		offset, err := l.Write(ctx, []byte("secret"))
+		assert.NilError(t, err)
+
+		assert.Assert(t, !bytes.Contains(store.records[0].Data, []byte("secret")))
+
+		r, err := l.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, []byte("secret"))
+		assert.Assert(t, r.Metadata.Extensions.KeyID != "")
+
+		v, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+		r, err = v.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, []byte("secret"))
+
+		b, err := l.ReadRangeBytes(ctx, offset, offset)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, b, []byte("secret"))
+
+		assert.NilError(t, l.Validate())
+	})
+
+	t.Run("reads records encrypted with rotated keys", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithEncryption("key-1", key1))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("first"))
+		assert.NilError(t, err)
+
+		assert.NilError(t, l.RotateEncryptionKey("key-2", key2))
+		_, err = l.Write(ctx, []byte("second"))
+		assert.NilError(t, err)
+
+		first, err := l.Read(ctx, 0)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, first.Data, []byte("first"))
+
+		second, err := l.Read(ctx, 1)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, second.Data, []byte("second"))
+		assert.Assert(t, first.Metadata.Extensions.KeyID != second.Metadata.Extensions.KeyID)
+
+		assert.Equal(t, first.Metadata.Extensions.KeyID, "key-1")
+		assert.Equal(t, second.Metadata.Extensions.KeyID, "key-2")
+
+		err = l.RotateEncryptionKey("key-3", []byte("invalid"))
+		assert.ErrorContains(t, err, "invalid encryption key")
+
+		err = l.RotateEncryptionKey("key-1", key2)
+		assert.ErrorContains(t, err, "already exists")
+
+		err = l.RotateEncryptionKey("", key2)
+		assert.ErrorContains(t, err, "must not be empty")
+	})
+
+	t.Run("restores encrypted write-ahead log", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		l, err := memlog.New(ctx, memlog.WithPersistence(dir), memlog.WithEncryption("key-1", key1))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("first"))
+		assert.NilError(t, err)
+		assert.NilError(t, l.RotateEncryptionKey("key-2", key2))
+		_, err = l.Write(ctx, []byte("second"))
+		assert.NilError(t, err)
+		assert.NilError(t, l.Close())
+
+		_, err = memlog.New(ctx, memlog.WithPersistence(dir))
+		assert.ErrorContains(t, err, "encryption is not enabled")
+
+		l, err = memlog.New(ctx, memlog.WithPersistence(dir), memlog.WithEncryption("key-1", key1), memlog.WithEncryption("key-2", key2))
+		assert.NilError(t, err)
+
+		r, err := l.Read(ctx, 1)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, []byte("second"))
+	})
+
+	t.Run("fails with deduplication", func(t *testing.T) {
+		keyFn := func(data []byte) []byte { return data }
+		_, err := memlog.New(context.Background(), memlog.WithEncryption("key-1", key1), memlog.WithDedupeKeyFunc(keyFn))
+		assert.ErrorContains(t, err, "not supported with deduplication")
+	})
+}
@@ -2,10 +2,20 @@ package memlog
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unsafe"
 
 	"github.com/benbjohnson/clock"
 )
@@ -20,11 +30,88 @@ var (
 	// ErrOutOfRange is returned when the specified offset is invalid for the log
 	// configuration or already purged from history
 	ErrOutOfRange = errors.New("offset out of range")
+	// ErrExtendFailed is returned on writes when the log could not be extended
+	// with a new active segment and the ExtendFailurePolicy does not panic
+	ErrExtendFailed = errors.New("extend log failed")
+	// ErrNonMonotonicTime is returned by WriteAt when the log was created with
+	// WithStrictTimeOrdering() and the specified creation time is before the
+	// creation time of the previous record
+	ErrNonMonotonicTime = errors.New("non-monotonic record timestamp")
+	// ErrNoRecord is returned on reads of an offset reserved with Reserve()
+	// which has not been written yet
+	ErrNoRecord = errors.New("no record at reserved offset")
+	// ErrNotReserved is returned by WriteReserved when the offset is not
+	// reserved or has already been written
+	ErrNotReserved = errors.New("offset not reserved")
+	// ErrOffsetConflict is returned by WriteExpect when the next write offset
+	// does not match the expected offset
+	ErrOffsetConflict = errors.New("offset conflict")
+	// ErrClosed is returned on writes and by streams when the log was closed with
+	// Close()
+	ErrClosed = errors.New("log closed")
+	// ErrReadOnly is returned on writes to a read replica created with
+	// Log.ReadReplica()
+	ErrReadOnly = errors.New("log is read-only")
+	// ErrCompacted is returned when reading a record which was removed by
+	// compaction, see WithCompaction()
+	ErrCompacted = errors.New("record removed by compaction")
+	// ErrChecksumMismatch is returned when reading a record whose data does not
+	// match its checksum, see WithChecksumVerification()
+	ErrChecksumMismatch = errors.New("record checksum mismatch")
+	// ErrChunkContinuation is returned when reading an offset which holds a
+	// continuation chunk of a record written in chunks, see WithChunking()
+	ErrChunkContinuation = errors.New("offset holds continuation chunk of record")
+	// ErrFiltered is returned by a ReadInterceptor to hide a record from
+	// readers, see WithReadInterceptor()
+	ErrFiltered = errors.New("record filtered by read interceptor")
+	// ErrDropped is returned by a WriteInterceptor to drop a record without
+	// failing the write, see WithWriteInterceptor()
+	ErrDropped = errors.New("record dropped by write interceptor")
 )
 
+// OffsetConflictError is returned by WriteExpect when the next write offset
+// does not match the expected offset, e.g. to retry with the next offset. It
+// matches ErrOffsetConflict with errors.Is.
+type OffsetConflictError struct {
+	// Expected is the offset expected by the caller
+	Expected Offset
+	// Next is the next write offset of the log
+	Next Offset
+}
+
+func (e *OffsetConflictError) Error() string {
+	return fmt.Sprintf("%v: expected offset %d, next offset %d", ErrOffsetConflict, e.Expected, e.Next)
+}
+
+// Is reports whether target is ErrOffsetConflict
+func (e *OffsetConflictError) Is(target error) bool {
+	return target == ErrOffsetConflict
+}
+
+var errNoTimestamps = errors.New("log created without timestamps")
+
+// errExpired is returned when reading a record whose TTL has passed, see
+// WriteTTL()
+var errExpired = fmt.Errorf("record expired: %w", ErrOutOfRange)
+
 // Offset is a monotonically increasing position of a record in the log
 type Offset int
 
+// InvalidOffset is returned by operations when no valid offset is available,
+// e.g. on write errors or for the offset range of an empty log
+const InvalidOffset = Offset(-1)
+
+const (
+	// OffsetEarliest is resolved to the earliest available offset by Read,
+	// ReadBatch and streams when they are called. If the log is empty, it is
+	// resolved to the next write offset.
+	OffsetEarliest = Offset(-2)
+	// OffsetLatest is resolved to the latest available offset by Read,
+	// ReadBatch and streams when they are called. If the log is empty, it is
+	// resolved to the next write offset.
+	OffsetLatest = Offset(-3)
+)
+
 // Header is metadata associated with a record
 type Header struct {
 	// Offset is the record offset relative to the log start
@@ -32,6 +119,59 @@ type Header struct {
 	// Created is the UTC timestamp when a record was successfully written to the
 	// log
 	Created time.Time `json:"created"` // UTC
+	// Seq is a monotonically increasing sequence number independent of the
+	// record offset, only set if the log was created with WithGlobalSequence()
+	Seq uint64 `json:"seq,omitempty"`
+	// Key is the key of the record, only set if the record was written with
+	// WriteKey()
+	Key []byte `json:"key,omitempty"`
+	// ID is the idempotency key of the record, only set if the record was
+	// written with WriteWithID()
+	ID string `json:"id,omitempty"`
+	// Checksum is the CRC-32 checksum of the record data using the Castagnoli
+	// polynomial
+	Checksum uint32 `json:"checksum,omitempty"`
+	// Extensions is the metadata of optional features, nil if the record was
+	// written without any of them
+	Extensions *Extensions `json:"extensions,omitempty"`
+}
+
+// Extensions is record metadata of optional features. It is kept separate from
+// Header so that logs which don't use these features don't pay for the memory.
+type Extensions struct {
+	// Expires is the UTC timestamp after which the record is no longer
+	// readable, only set if the record was written with WriteTTL(). The
+	// timestamp must not be modified.
+	Expires *time.Time `json:"expires,omitempty"` // UTC
+	// Attributes are custom attributes of the record, e.g. content type or
+	// trace ID, only set if the record was written with WriteRecord()
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// KeyID is the ID of the key the record data is encrypted with in the log,
+	// only set if the log was created with WithEncryption(). Reads return the
+	// decrypted data.
+	KeyID string `json:"key_id,omitempty"`
+	// Chunk is the index of the chunk of a record written in chunks, see
+	// WithChunking()
+	Chunk int `json:"chunk,omitempty"`
+	// Chunks is the number of chunks of a record written in chunks, only set if
+	// the record is larger than the maximum record size
+	Chunks int `json:"chunks,omitempty"`
+}
+
+// ext returns the extensions of h, the zero value if h has none
+func (h Header) ext() Extensions {
+	if h.Extensions == nil {
+		return Extensions{}
+	}
+	return *h.Extensions
+}
+
+// extend replaces the extensions of h with a copy which can be modified, i.e.
+// extensions shared with other copies of h are not changed
+func (h *Header) extend() *Extensions {
+	ext := h.ext()
+	h.Extensions = &ext
+	return h.Extensions
 }
 
 // Record is an immutable entry in the log
@@ -41,24 +181,155 @@ type Record struct {
 }
 
 func (r Record) deepCopy() Record {
-	if r.Metadata.Offset == 0 && r.Metadata.Created.IsZero() {
+	// written records always have data, i.e. a record at offset 0 without a
+	// creation time, see WithoutTimestamps(), is not empty
+	if r.Data == nil && r.Metadata.Offset == 0 && r.Metadata.Created.IsZero() {
 		return Record{}
 	}
 	dCopy := make([]byte, len(r.Data))
 	copy(dCopy, r.Data)
+	var kCopy []byte
+	if r.Metadata.Key != nil {
+		kCopy = make([]byte, len(r.Metadata.Key))
+		copy(kCopy, r.Metadata.Key)
+	}
+	var eCopy *Extensions
+	if r.Metadata.Extensions != nil {
+		ext := *r.Metadata.Extensions
+		ext.Attributes = copyAttributes(ext.Attributes)
+		eCopy = &ext
+	}
 	return Record{
 		Metadata: Header{
-			Offset:  r.Metadata.Offset,
-			Created: r.Metadata.Created,
+			Offset:     r.Metadata.Offset,
+			Created:    r.Metadata.Created,
+			Seq:        r.Metadata.Seq,
+			Key:        kCopy,
+			ID:         r.Metadata.ID,
+			Checksum:   r.Metadata.Checksum,
+			Extensions: eCopy,
 		},
 		Data: dCopy,
 	}
 }
 
+// crcTable is the CRC-32 table of record checksums
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum returns the checksum of the record data
+func checksum(data []byte) uint32 {
+	return crc32.Checksum(data, crcTable)
+}
+
+// copyAttributes returns a copy of attrs, nil if attrs is empty
+func copyAttributes(attrs map[string]string) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	aCopy := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		aCopy[k] = v
+	}
+	return aCopy
+}
+
+// expired returns true if the record was written with a TTL which has passed at
+// now
+func (r Record) expired(now time.Time) bool {
+	expires := r.Metadata.ext().Expires
+	return expires != nil && !now.Before(*expires)
+}
+
+// WithData returns a deep copy of the record with the data replaced by a copy
+// of data. The record metadata is preserved.
+func (r Record) WithData(data []byte) Record {
+	dCopy := make([]byte, len(data))
+	copy(dCopy, data)
+	rCopy := r.deepCopy()
+	rCopy.Data = dCopy
+	return rCopy
+}
+
 type config struct {
-	startOffset   Offset // logical start offset
-	segmentSize   int    // offsets per segment
-	maxRecordSize int    // bytes
+	name            string        // label, empty by default
+	startOffset     Offset        // logical start offset
+	segmentSize     int           // offsets per segment
+	maxRecordSize   int           // bytes
+	maxPreallocSize int           // bytes
+	maxReadBatch    int           // records, 0 means no limit
+	readYield       int           // records, 0 means no yield
+	monotonic       bool          // clamp record timestamps
+	strictTime      bool          // reject non-monotonic custom timestamps
+	noTimestamps    bool          // do not stamp records with the clock
+	verifyChecksums bool          // verify record checksums on reads
+	chunking        bool          // split records larger than maxRecordSize
+	compaction      bool          // remove records superseded by key
+	dedupe          bool          // detect duplicate records by key function
+	encryption      bool          // encrypt record data
+	customStore     bool          // segment store other than the default
+	rateTracker     bool          // track the write rate
+	timeIndex       bool          // index offsets by creation time
+	eof             bool          // return io.EOF instead of ErrFutureOffset
+	streamRate      int           // records per second, 0 means no limit
+	defaultTimeout  time.Duration // blocking operations, 0 means no timeout
+	linearSearch    bool          // linear history search, benchmarking only
+	persistDir      string        // write-ahead log directory, empty if disabled
+	retention       time.Duration // maximum record age, 0 means no limit
+	dedupeWindow    time.Duration // 0 means until purged
+	purgeBatch      int           // history segments purged at once
+	maxHistory      int           // history segments retained
+	sequence        bool          // stamp records with global sequence
+	extendPolicy    ExtendFailurePolicy
+}
+
+// validate checks the assembled configuration for invalid combinations of
+// options which can not be detected by validating a single option. All errors
+// are combined into the returned error.
+func (c config) validate() error {
+	var errs []string
+
+	// active and history segments
+	prealloc := uint64(c.historySegments()+1) * uint64(c.segmentSize) * uint64(unsafe.Sizeof(Record{}))
+	if prealloc > uint64(c.maxPreallocSize) {
+		errs = append(errs, fmt.Sprintf("estimated segment preallocation of %d bytes exceeds maximum of %d bytes", prealloc, c.maxPreallocSize))
+	}
+
+	// offsets of the first two segments must not overflow
+	if c.startOffset > Offset(math.MaxInt-c.segmentSize-c.segmentSize) {
+		errs = append(errs, "start offset too large for segment size")
+	}
+
+	if c.noTimestamps && (c.timeIndex || c.rateTracker || c.retention > 0 || c.dedupeWindow > 0) {
+		errs = append(errs, "time index, rate tracker, retention and dedupe window require timestamps")
+	}
+
+	if c.chunking && c.dedupe {
+		errs = append(errs, "chunking is not supported with deduplication")
+	}
+
+	if c.encryption && c.dedupe {
+		errs = append(errs, "encryption is not supported with deduplication")
+	}
+
+	if c.compaction && c.customStore {
+		errs = append(errs, "compaction requires the default segment store")
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// historySegments returns the maximum number of history segments, which are
+// retained until the active segment is full
+func (c config) historySegments() int {
+	if c.purgeBatch > c.maxHistory {
+		return c.purgeBatch
+	}
+	return c.maxHistory
 }
 
 // Log is an append-only in-memory data structure storing records. Records are
@@ -73,22 +344,59 @@ type config struct {
 // The maximum number of records in a log is twice the configured segment size
 // (active + history). When this limit is reached, the history segment is
 // purged, replaced with the current active segment and a new empty active
-// segment is created.
+// segment is created. With WithMaxHistorySegments(), multiple history segments
+// are retained and the oldest is purged. With WithPurgeBatch(), multiple
+// history segments are retained and purged at once.
 //
 // Safe for concurrent use.
 type Log struct {
+	reads uint64 // accessed atomically, first for 64-bit alignment
+
 	conf config
 
-	mu      sync.RWMutex
-	history *segment // read-only
-	active  *segment // read-write
-	offset  Offset   // monotonic offset counter tracking next write
-	clock   clock.Clock
+	mu            sync.RWMutex
+	history       []*segment // read-only, oldest first
+	active        *segment   // read-write
+	offset        Offset     // monotonic offset counter tracking next write
+	floor         Offset     // earliest readable offset, see Truncate()
+	purged        bool       // true if history was purged at least once
+	purges        uint64     // number of purges
+	created       uint64     // number of segments created
+	destroyed     uint64     // number of segments purged
+	bytes         int        // data bytes of records retained in memory
+	records       int        // records with data retained in memory
+	truncated     int        // records with data retained in memory before floor
+	writes        uint64     // number of records written
+	seq           uint64     // global sequence of the next write
+	clock         clock.Clock
+	lastWrite     time.Time     // creation time of the last written record
+	fault         FaultInjector // testing only
+	intercept     WriteInterceptor
+	readIntercept ReadInterceptor                         // nil if disabled
+	newStore      func(size int) SegmentStore             // nil uses the default store
+	purgeFn       func(ctx context.Context, seg []Record) // nil if disabled
+	rate          *rateTracker                            // nil if disabled
+	dedupe        *deduper                                // nil if disabled
+	compactor     *compactor                              // nil if disabled
+	keys          *keyring                                // nil if encryption is disabled
+	ids           map[string]Offset                       // idempotency keys, see WriteWithID()
+	timeIdx       *timeIndex                              // nil if disabled
+	reserved      map[Offset]struct{}                     // reserved offsets not written yet
+	dead          *deadLetters                            // nil if disabled
+	firstHook     func(offset Offset)                     // nil if disabled or fired
+	values        map[interface{}]interface{}             // immutable after New
+	closed        bool
+	restoring     bool // replaying the write-ahead log, see restore()
+	readOnly      bool // read replica
+	wal           *wal // nil if disabled
+
+	notifyMu sync.Mutex
+	changed  chan struct{} // lazily created, closed on log modification
 }
 
 // New creates an empty log with default options applied, unless specified
 // otherwise.
-func New(_ context.Context, options ...Option) (*Log, error) {
+func New(ctx context.Context, options ...Option) (*Log, error) {
 	var l Log
 
 	// apply defaults
@@ -98,239 +406,2467 @@ func New(_ context.Context, options ...Option) (*Log, error) {
 		}
 	}
 
-	// apply custom settings
+	// apply custom settings, collecting all errors
+	var errs []string
 	for _, opt := range options {
 		if err := opt(&l); err != nil {
-			return nil, fmt.Errorf("configure log custom option: %v", err)
+			errs = append(errs, err.Error())
 		}
 	}
 
-	s, err := newSegment(l.conf.startOffset, l.conf.segmentSize)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("configure log custom option: %s", strings.Join(errs, "; "))
+	}
+
+	if err := l.conf.validate(); err != nil {
+		return nil, fmt.Errorf("validate log configuration: %v", err)
+	}
+
+	s, err := l.newSegment(l.conf.startOffset)
 	if err != nil {
 		return nil, fmt.Errorf("create active segment: %v", err)
 	}
 	l.active = s
 	l.offset = l.conf.startOffset
+	l.floor = l.conf.startOffset
+	l.created++
+
+	if l.conf.persistDir != "" {
+		w, err := openWAL(l.conf.persistDir)
+		if err != nil {
+			return nil, fmt.Errorf("open write-ahead log: %v", err)
+		}
+
+		// segments purged while restoring might have been passed to the purge
+		// handler before the restart
+		l.restoring = true
+		if err = w.replay(l.restore); err != nil {
+			_ = w.close()
+			return nil, fmt.Errorf("replay write-ahead log: %v", err)
+		}
+		l.wal = w
+
+		if l.conf.retention > 0 {
+			l.expire(ctx)
+		}
+		l.restoring = false
+	}
 
 	return &l, nil
 }
 
-// Write creates a new record in the log with the provided data. The write offset
-// of the new record is returned. If an error occurs, an invalid offset (-1) and
-// the error is returned.
-//
-// Safe for concurrent use.
-func (l *Log) Write(ctx context.Context, data []byte) (Offset, error) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	return l.write(ctx, data)
-}
+// restore appends a record replayed from the write-ahead log, preserving its
+// metadata. Interceptors and hooks are not applied. Must be protected with a
+// lock by the caller.
+func (l *Log) restore(r Record) error {
+	// the write-ahead log was compacted, see CompactWAL()
+	if r.Metadata.Offset > l.offset && l.offset == l.conf.startOffset && len(l.history) == 0 && l.active.len() == 0 {
+		s, err := l.newSegment(r.Metadata.Offset)
+		if err != nil {
+			return err
+		}
+		_ = l.active.store.Close()
 
-func (l *Log) write(ctx context.Context, data []byte) (Offset, error) {
-	if ctx.Err() != nil {
-		return -1, ctx.Err()
+		l.active = s
+		l.offset = r.Metadata.Offset
+		l.floor = r.Metadata.Offset
+		l.purged = true
 	}
 
-	if len(data) > l.conf.maxRecordSize {
-		return -1, ErrRecordTooLarge
+	if r.Metadata.Offset != l.offset {
+		return fmt.Errorf("record offset %d does not match next offset %d", r.Metadata.Offset, l.offset)
 	}
 
-	if len(data) == 0 {
-		return -1, errors.New("no data provided")
+	if r.Metadata.ext().KeyID != "" && l.keys == nil {
+		return fmt.Errorf("record at offset %d is encrypted but encryption is not enabled", r.Metadata.Offset)
 	}
 
-	dCopy := make([]byte, len(data))
-	copy(dCopy, data)
-	r := Record{
-		Metadata: Header{
-			Offset:  l.offset,
-			Created: l.clock.Now().UTC(),
-		},
-		Data: dCopy,
+	// written before checksums were added
+	if r.Metadata.Checksum == 0 && r.Metadata.ext().KeyID == "" {
+		r.Metadata.Checksum = checksum(r.Data)
 	}
 
-	err := l.active.write(ctx, r)
-	for err != nil {
-		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-			return -1, err
-		}
-
-		if errors.Is(err, errFull) {
-			err = l.extend()
-			if err != nil {
-				panic(err.Error()) // abnormal program state
-			}
+	if err := l.append(context.Background(), r); err != nil {
+		return err
+	}
 
-			err = l.active.write(ctx, r)
-			continue
-		}
+	l.offset++
+	if l.conf.sequence {
+		l.seq = r.Metadata.Seq + 1
+	}
+	if !r.Metadata.Created.IsZero() {
+		l.lastWrite = r.Metadata.Created
+	}
 
-		if errors.Is(err, errSealed) {
-			panic(err.Error()) // abnormal program state
-		}
+	// removed by compaction before the write-ahead log was compacted
+	if r.Data == nil {
+		return nil
+	}
 
-		// logger.Error(err, "segment write failed")
-		panic("write error: " + err.Error())
+	l.bytes += len(r.Data)
+	l.records++
+	if l.dedupe != nil {
+		l.dedupe.add(r.Data, r.Metadata.Offset)
 	}
+	if r.Metadata.ID != "" {
+		l.addID(r.Metadata.ID, r.Metadata.Offset)
+	}
+	if l.compactor != nil {
+		l.compactor.add(r.Metadata.Key, r.Metadata.Offset)
+	}
+	if l.timeIdx != nil {
+		l.timeIdx.add(r.Metadata.Created, r.Metadata.Offset)
+	}
+	return nil
+}
 
-	l.offset++
-	return r.Metadata.Offset, nil
+// Write creates a new record in the log with the provided data. The write offset
+// of the new record is returned. If an error occurs, InvalidOffset and
+// the error is returned. If the record was dropped by a WriteInterceptor, the
+// next (unused) write offset and no error is returned. If the record is a
+// duplicate (see WithDedupeKeyFunc), the offset of the existing record and no
+// error is returned.
+//
+// Safe for concurrent use.
+func (l *Log) Write(ctx context.Context, data []byte) (Offset, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.write(ctx, data)
 }
 
-// Read reads a record from the log at the specified offset. If an error occurs, an
-// invalid (empty) record and the error is returned.
+// WriteV is like Write but creates a single record with the concatenation of
+// data, e.g. to write a payload assembled from multiple buffers without joining
+// them before. The concatenated size is validated against the maximum record
+// size. Reads return the concatenated data.
 //
 // Safe for concurrent use.
-func (l *Log) Read(ctx context.Context, offset Offset) (Record, error) {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+func (l *Log) WriteV(ctx context.Context, data ...[]byte) (Offset, error) {
+	var size int
+	for _, d := range data {
+		size += len(d)
+	}
+
+	// the joined data is not copied again by the write
+	joined := make([]byte, 0, size)
+	for _, d := range data {
+		joined = append(joined, d...)
+	}
 
-	return l.read(ctx, offset)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offset, _, err := l.tryWrite(ctx, writeOptions{owned: true}, joined)
+	return offset, err
 }
 
-// ReadBatch reads multiple records into batch starting at the specified offset.
-// The number of records read into batch and the error, if any, is returned.
-//
-// ReadBatch will read at most len(batch) records, always starting at batch
-// index 0. ReadBatch stops reading at the end of the log, indicated by
-// ErrFutureOffset.
+// WriteBatch creates a record for each element of data under a single lock
+// acquisition, e.g. for producers writing in bursts. The records are written
+// with contiguous offsets and the same creation time, and their offsets are
+// returned in order. If an error occurs, no record is written and nil and the
+// error is returned. Only a failing custom segment store (see
+// WithSegmentStore()) can fail the batch after records were written, in which
+// case the offsets of the written records and the error are returned.
 //
-// The caller must expect partial batch results and must not read more records
-// from batch than indicated by the returned number of records. See the example
-// for how to use this API.
+// The batch must not be empty and must not contain more records than the
+// segment size. Batches are not supported if the log was created with a
+// WriteInterceptor or WithDedupeKeyFunc(), which might drop records of the
+// batch.
 //
 // Safe for concurrent use.
-func (l *Log) ReadBatch(ctx context.Context, offset Offset, batch []Record) (int, error) {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-
-	for i := 0; i < len(batch); i++ {
-		r, err := l.read(ctx, offset)
-		if err != nil {
-			// invalid start offset or empty log
-			if errors.Is(err, ErrOutOfRange) {
-				return 0, err
-			}
+func (l *Log) WriteBatch(ctx context.Context, data [][]byte) ([]Offset, error) {
+	if len(data) == 0 || len(data) > l.conf.segmentSize {
+		return nil, errors.New("batch must not be empty and not larger than the segment size")
+	}
 
-			// return what we have
-			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-				return i, err
-			}
+	if l.intercept != nil || l.dedupe != nil {
+		return nil, errors.New("batches are not supported with write interceptors or deduplication")
+	}
 
-			// end of log
-			if errors.Is(err, ErrFutureOffset) {
-				return i, ErrFutureOffset
-			}
+	for _, d := range data {
+		if len(d) > l.conf.maxRecordSize {
+			return nil, ErrRecordTooLarge
+		}
 
+		if len(d) == 0 {
+			return nil, errors.New("no data provided")
 		}
-		batch[i] = r
-		offset++
 	}
 
-	return len(batch), nil
-}
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-func (l *Log) read(ctx context.Context, offset Offset) (Record, error) {
 	if ctx.Err() != nil {
-		return Record{}, ctx.Err()
+		return nil, ctx.Err()
 	}
 
-	if offset >= l.offset {
-		return Record{}, ErrFutureOffset
+	if l.closed {
+		return nil, ErrClosed
 	}
 
-	if offset < l.conf.startOffset {
-		return Record{}, ErrOutOfRange
+	if l.readOnly {
+		return nil, ErrReadOnly
 	}
 
-	s, err := l.getSegment(offset)
-	if err != nil {
-		return Record{}, err
+	if l.fault != nil {
+		for i := range data {
+			if err := l.fault(FaultOpWrite, l.offset+Offset(i)); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	r, err := s.read(ctx, offset)
-	if err != nil {
-		return Record{}, err
+	var now time.Time
+	if !l.conf.noTimestamps {
+		now = l.clock.Now().UTC()
 	}
 
-	return r.deepCopy(), nil
-}
+	if l.conf.monotonic && !now.IsZero() && now.Before(l.lastWrite) {
+		// clock went backwards
+		now = l.lastWrite
+	}
 
-// Range returns the earliest and latest available record offset in the log. If
-// the log is empty, an invalid offset (-1) for both return values is returned.
-// If the log has been purged one or more times, earliest points to the oldest
-// available record offset in the log, i.e. not the configured start offset.
-//
-// Note that these values might have changed after retrieval, e.g. due to
-// concurrent writes.
-//
-// Safe for concurrent use.
-func (l *Log) Range(_ context.Context) (earliest, latest Offset) {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+	records := make([]Record, len(data))
+	for i, d := range data {
+		dCopy := make([]byte, len(d))
+		copy(dCopy, d)
+		records[i] = Record{
+			Metadata: Header{
+				Created: now,
+			},
+			Data: dCopy,
+		}
+	}
 
-	earliest, latest = l.offsetRange()
-	return
+	return l.writeBatch(ctx, records)
 }
 
-// offsetRange returns the earliest and latest available record offset in the
-// log. If the log is empty, -1 for both return values is returned. If the log
-// has been purged one or more times, earliest points to the oldest available
-// record offset in the log, i.e. not the configured start offset. Must be
-// protected with a lock by the caller.
-func (l *Log) offsetRange() (Offset, Offset) {
-	if l.history == nil {
-		// empty log
-		if l.active.currentOffset() == -1 {
-			return -1, -1
+// writeBatch atomically writes records with contiguous offsets, see
+// WriteBatch(). The offset, sequence number and checksum of the records are set
+// and the records are encrypted before writing. The number of records must not
+// be greater than the segment size. Must be protected with a lock by the
+// caller.
+func (l *Log) writeBatch(ctx context.Context, records []Record) ([]Offset, error) {
+	for i := range records {
+		records[i].Metadata.Offset = l.offset + Offset(i)
+		records[i].Metadata.Checksum = checksum(records[i].Data)
+		if l.conf.sequence {
+			records[i].Metadata.Seq = l.seq + uint64(i)
 		}
 
-		// no purge since start
-		return l.conf.startOffset, l.active.currentOffset()
+		r, err := l.encrypt(records[i])
+		if err != nil {
+			return nil, err
+		}
+		records[i] = r
 	}
 
-	return l.history.start, l.active.currentOffset()
-}
+	if l.conf.retention > 0 {
+		l.expire(ctx)
+	}
 
-// getSegment retrieves the segment for the specified offset. If the offset is
-// in the future, ErrFutureOffset will be returned. If the offset is invalid or
-// has been purged ErrOutOfRange is returned. Must be protected with a lock by
-// the caller.
-func (l *Log) getSegment(offset Offset) (*segment, error) {
-	// check if offset is within active segment
-	if offset >= l.active.start {
-		if offset <= l.active.currentOffset() {
-			return l.active, nil
+	// create the next segment before writing to not fail with a partially
+	// written batch
+	var next *segment
+	if free := l.active.size - l.active.len(); len(records) > free {
+		seg, err := l.newSegment(l.active.start + Offset(l.active.size))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrExtendFailed, err)
 		}
-		return nil, ErrFutureOffset
+		next = seg
 	}
 
-	// search history
-	history := l.history
-	if history != nil {
-		min := history.start
-		max := history.start + Offset(l.conf.segmentSize) - 1
+	// write-ahead log size before each record
+	var walSizes []int64
+	if l.wal != nil {
+		walSizes = make([]int64, len(records))
+		for i, r := range records {
+			walSizes[i] = l.wal.size
+			if err := l.wal.append(r); err != nil {
+				if tErr := l.wal.truncate(walSizes[0]); tErr != nil {
+					err = fmt.Errorf("%w (truncate: %v)", err, tErr)
+				}
+				return nil, fmt.Errorf("write to write-ahead log: %w", err)
+			}
+		}
+	}
 
-		if min <= offset && offset <= max {
-			return history, nil
+	offsets := make([]Offset, 0, len(records))
+	for i, r := range records {
+		if l.active.len() == l.active.size {
+			l.rollover(ctx, next)
+		}
+
+		// the batch is validated, i.e. writes only fail in custom stores
+		if err := l.active.write(context.Background(), r); err != nil {
+			if l.wal != nil {
+				if tErr := l.wal.truncate(walSizes[i]); tErr != nil {
+					err = fmt.Errorf("%w (truncate write-ahead log: %v)", err, tErr)
+				}
+			}
+
+			if len(offsets) == 0 {
+				return nil, err
+			}
+
+			l.notify()
+			return offsets, err
 		}
+
+		l.commit(r)
+		offsets = append(offsets, r.Metadata.Offset)
 	}
-	return nil, ErrOutOfRange
+
+	l.notify()
+	return offsets, nil
 }
 
-// extend creates a new active and history segment by replacing it with the
-// current active segment. The old segment is sealed. If history is not empty,
-// history will be purged before replacing it. Must be protected with a lock by
-// the caller.
-func (l *Log) extend() error {
-	l.active.seal()
+// TryWrite is like Write but additionally reports whether the record was
+// written. If a WriteInterceptor dropped the record, the next (unused) write
+// offset, false and no error is returned. If the record is a duplicate, the
+// offset of the existing record, false and no error is returned.
+//
+// Safe for concurrent use.
+func (l *Log) TryWrite(ctx context.Context, data []byte) (offset Offset, written bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tryWrite(ctx, writeOptions{}, data)
+}
 
-	l.history = l.active
-	seg, err := newSegment(l.offset, l.conf.segmentSize)
-	if err != nil {
-		return err
+// WriteAt is like Write but creates the record with the specified creation
+// time instead of the time of the log clock, e.g. to faithfully replay records.
+// The creation time must not be zero and is converted to UTC. If the creation
+// time is before the creation time of the previous record, it is clamped if the
+// log was created with WithMonotonicTimestamps() or rejected with
+// ErrNonMonotonicTime if the log was created with WithStrictTimeOrdering().
+//
+// Safe for concurrent use.
+func (l *Log) WriteAt(ctx context.Context, created time.Time, data []byte) (Offset, error) {
+	if created.IsZero() {
+		return InvalidOffset, errors.New("creation time must not be zero")
 	}
 
-	l.active = seg
-	return nil
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offset, _, err := l.tryWrite(ctx, writeOptions{created: created.UTC()}, data)
+	return offset, err
+}
+
+// WriteTTL is like Write but the record expires after ttl based on the clock of
+// the log, e.g. for ephemeral events which must not be served once stale. Reads
+// of an expired record return ErrOutOfRange, batch reads and streams skip
+// expired records. Expired records are retained in memory until their segment
+// is purged, which happens early with Expire if all records in the segment are
+// expired. ttl must be greater than 0.
+//
+// Safe for concurrent use.
+func (l *Log) WriteTTL(ctx context.Context, ttl time.Duration, data []byte) (Offset, error) {
+	if ttl <= 0 {
+		return InvalidOffset, errors.New("ttl must be greater than 0")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offset, _, err := l.tryWrite(ctx, writeOptions{ttl: ttl}, data)
+	return offset, err
+}
+
+// WriteRecord is like Write but creates the record with the data and the
+// caller-provided metadata of r, i.e. the attributes, key (see WriteKey()) and
+// id (see WriteWithID()), e.g. to attach a content type or trace ID without
+// encoding it in the data. The attributes are returned verbatim by reads and
+// streams. All other metadata of r, e.g. the offset, is set by the log.
+//
+// Safe for concurrent use.
+func (l *Log) WriteRecord(ctx context.Context, r Record) (Offset, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	opts := writeOptions{
+		key:   r.Metadata.Key,
+		id:    r.Metadata.ID,
+		attrs: r.Metadata.ext().Attributes,
+	}
+
+	offset, _, err := l.tryWrite(ctx, opts, r.Data)
+	return offset, err
+}
+
+// WriteExpect is like Write but only writes the record if the next write offset
+// of the log is the expected offset, e.g. for exactly-once ingestion from an
+// upstream with sequence numbers or optimistic concurrency control between
+// producers. Otherwise InvalidOffset and an *OffsetConflictError matching
+// ErrOffsetConflict is returned.
+//
+// Safe for concurrent use.
+func (l *Log) WriteExpect(ctx context.Context, expected Offset, data []byte) (Offset, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.offset != expected {
+		return InvalidOffset, &OffsetConflictError{Expected: expected, Next: l.offset}
+	}
+
+	return l.write(ctx, data)
+}
+
+func (l *Log) write(ctx context.Context, data []byte) (Offset, error) {
+	offset, _, err := l.tryWrite(ctx, writeOptions{}, data)
+	return offset, err
+}
+
+// writeOptions are the optional record settings of a write
+type writeOptions struct {
+	created time.Time         // zero uses the time of the log clock
+	key     []byte            // nil if not set, see WriteKey()
+	id      string            // empty if not set, see WriteWithID()
+	attrs   map[string]string // nil if not set, see WriteRecord()
+	ttl     time.Duration     // 0 means no expiry, see WriteTTL()
+	owned   bool              // data is a private copy, see WriteV()
+}
+
+// tryWrite writes data with the specified write options
+func (l *Log) tryWrite(ctx context.Context, opts writeOptions, data []byte) (Offset, bool, error) {
+	if ctx.Err() != nil {
+		return InvalidOffset, false, ctx.Err()
+	}
+
+	if l.closed {
+		return InvalidOffset, false, ErrClosed
+	}
+
+	if l.readOnly {
+		return InvalidOffset, false, ErrReadOnly
+	}
+
+	if l.fault != nil {
+		if err := l.fault(FaultOpWrite, l.offset); err != nil {
+			return InvalidOffset, false, err
+		}
+	}
+
+	if l.intercept != nil {
+		newData, err := l.intercept(context.WithValue(ctx, writeOffsetKey{}, l.offset), data)
+		if errors.Is(err, ErrDropped) {
+			return l.offset, false, nil
+		}
+
+		if err != nil {
+			l.reject(data)
+			return InvalidOffset, false, err
+		}
+
+		if newData != nil {
+			data = newData
+			opts.owned = false
+		}
+	}
+
+	chunks := (len(data) + l.conf.maxRecordSize - 1) / l.conf.maxRecordSize
+	if chunks > 1 && (!l.conf.chunking || chunks > l.conf.segmentSize) {
+		l.reject(data)
+		return InvalidOffset, false, ErrRecordTooLarge
+	}
+
+	if len(data) == 0 {
+		return InvalidOffset, false, errors.New("no data provided")
+	}
+
+	if l.dedupe != nil {
+		if offset, ok := l.dedupe.lookup(data); ok && l.inDedupeWindow(offset) {
+			return offset, false, nil
+		}
+	}
+
+	if opts.id != "" {
+		if offset, ok := l.ids[opts.id]; ok && l.inDedupeWindow(offset) {
+			return offset, false, nil
+		}
+	}
+
+	now := opts.created
+	if now.IsZero() && !l.conf.noTimestamps {
+		now = l.clock.Now().UTC()
+	} else if l.conf.strictTime && now.Before(l.lastWrite) {
+		return InvalidOffset, false, ErrNonMonotonicTime
+	}
+
+	if l.conf.monotonic && !now.IsZero() && now.Before(l.lastWrite) {
+		// clock went backwards
+		now = l.lastWrite
+	}
+
+	dCopy := data
+	if !opts.owned {
+		dCopy = make([]byte, len(data))
+		copy(dCopy, data)
+	}
+	r := Record{
+		Metadata: Header{
+			Offset:   l.offset,
+			Created:  now,
+			Checksum: checksum(dCopy),
+		},
+		Data: dCopy,
+	}
+
+	if opts.key != nil {
+		r.Metadata.Key = make([]byte, len(opts.key))
+		copy(r.Metadata.Key, opts.key)
+	}
+
+	if opts.ttl > 0 {
+		expires := l.clock.Now().UTC().Add(opts.ttl)
+		r.Metadata.extend().Expires = &expires
+	}
+
+	r.Metadata.ID = opts.id
+	if len(opts.attrs) > 0 {
+		r.Metadata.extend().Attributes = copyAttributes(opts.attrs)
+	}
+
+	if chunks > 1 {
+		offsets, err := l.writeBatch(ctx, l.chunk(r))
+		if err != nil {
+			return InvalidOffset, false, err
+		}
+		return offsets[0], true, nil
+	}
+
+	if l.conf.sequence {
+		r.Metadata.Seq = l.seq
+	}
+
+	r, err := l.encrypt(r)
+	if err != nil {
+		return InvalidOffset, false, err
+	}
+
+	if l.conf.retention > 0 {
+		l.expire(ctx)
+	}
+
+	if l.wal != nil {
+		if err := l.wal.append(r); err != nil {
+			return InvalidOffset, false, fmt.Errorf("write to write-ahead log: %w", err)
+		}
+	}
+
+	if err := l.append(ctx, r); err != nil {
+		if l.wal != nil {
+			if rbErr := l.wal.rollback(); rbErr != nil {
+				return InvalidOffset, false, fmt.Errorf("%w (rollback write-ahead log: %v)", err, rbErr)
+			}
+		}
+		return InvalidOffset, false, err
+	}
+
+	l.commit(r)
+	l.notify()
+	return r.Metadata.Offset, true, nil
+}
+
+// commit updates the log state after r was appended. Must be protected with a
+// lock by the caller.
+func (l *Log) commit(r Record) {
+	l.offset++
+	if l.conf.sequence {
+		l.seq++
+	}
+	l.track(r)
+}
+
+// track updates the accounting and indexes of the log after r was written,
+// either appended or filled at a reserved offset. Must be protected with a
+// lock by the caller.
+func (l *Log) track(r Record) {
+	now := r.Metadata.Created
+
+	l.writes++
+	l.bytes += len(r.Data)
+	l.records++
+	if l.firstHook != nil {
+		l.firstHook(r.Metadata.Offset)
+		l.firstHook = nil
+	}
+	if !now.IsZero() {
+		l.lastWrite = now
+	}
+	if l.rate != nil {
+		l.rate.add(now)
+	}
+	if l.dedupe != nil {
+		l.dedupe.add(r.Data, r.Metadata.Offset)
+	}
+	if r.Metadata.ID != "" {
+		l.addID(r.Metadata.ID, r.Metadata.Offset)
+	}
+	if l.compactor != nil {
+		l.compactor.add(r.Metadata.Key, r.Metadata.Offset)
+	}
+	if l.timeIdx != nil {
+		l.timeIdx.add(now, r.Metadata.Offset)
+	}
+}
+
+// reject adds the data of a rejected write to the dead letters if enabled.
+// Must be protected with a lock by the caller.
+func (l *Log) reject(data []byte) {
+	if l.dead != nil {
+		l.dead.add(data, l.clock.Now().UTC())
+	}
+}
+
+// DeadLetters returns copies of the data of the most recent writes rejected by
+// a WriteInterceptor or because the data was too large, oldest first. The
+// records have the offset InvalidOffset and are created with the time of the
+// rejection. If dead letters are not enabled with WithDeadLetter(), nil
+// is returned.
+//
+// Safe for concurrent use.
+func (l *Log) DeadLetters() []Record {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.dead == nil {
+		return nil
+	}
+
+	return l.dead.list()
+}
+
+// append appends r to the active segment and extends the log if the active
+// segment is full. Must be protected with a lock by the caller.
+func (l *Log) append(ctx context.Context, r Record) error {
+	err := l.active.write(ctx, r)
+	for err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		if errors.Is(err, errFull) {
+			err = l.extendWithPolicy(ctx)
+			if err != nil {
+				return err
+			}
+
+			err = l.active.write(ctx, r)
+			continue
+		}
+
+		if errors.Is(err, errSealed) {
+			panic(err.Error()) // abnormal program state
+		}
+
+		// segment store error, the record is not written
+		return err
+	}
+
+	return nil
+}
+
+// Read reads a record from the log at the specified offset. If an error occurs, an
+// invalid (empty) record and the error is returned. If the log was created
+// with WithEOFSemantics(), io.EOF is returned instead of ErrFutureOffset.
+// offset might be OffsetEarliest or OffsetLatest.
+//
+// Safe for concurrent use.
+func (l *Log) Read(ctx context.Context, offset Offset) (Record, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	r, err := l.read(ctx, l.resolveOffset(offset))
+	return r, l.eofError(err)
+}
+
+// ReadPrev reads the record immediately before the specified offset, i.e. at
+// offset-1, e.g. to retrieve the predecessor of an event. If offset is the next
+// write offset, the latest record is returned. If the predecessor is purged or
+// before the start offset of the log, ErrOutOfRange is returned. Otherwise
+// errors are returned like in Read.
+//
+// Safe for concurrent use.
+func (l *Log) ReadPrev(ctx context.Context, offset Offset) (Record, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// also guards against underflow
+	if offset <= l.conf.startOffset {
+		if ctx.Err() != nil {
+			return Record{}, ctx.Err()
+		}
+		return Record{}, ErrOutOfRange
+	}
+
+	r, err := l.read(ctx, offset-1)
+	return r, l.eofError(err)
+}
+
+// ReadMany reads the records at the specified offsets, which do not need to be
+// contiguous, under a single lock acquisition. The returned records are aligned
+// with offsets. ReadMany fails fast: if reading any offset fails, a nil slice
+// and the error is returned.
+//
+// Safe for concurrent use.
+func (l *Log) ReadMany(ctx context.Context, offsets []Offset) ([]Record, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	records := make([]Record, len(offsets))
+	for i, offset := range offsets {
+		r, err := l.read(ctx, offset)
+		if err != nil {
+			return nil, fmt.Errorf("read offset %d: %w", offset, err)
+		}
+		records[i] = r
+	}
+
+	return records, nil
+}
+
+// ReadRangeBytes returns the concatenated data of all records in the closed
+// interval [from,to], e.g. to compute a digest over a range of records. Record
+// boundaries are lost in the result. Records are read like in Read, i.e.
+// checksums are verified and read interceptors are applied. Records which are
+// skipped by batch reads, e.g. compacted or expired records, are not included.
+// If an error occurs, nil and the error is returned.
+//
+// Safe for concurrent use.
+func (l *Log) ReadRangeBytes(ctx context.Context, from, to Offset) ([]byte, error) {
+	if from > to {
+		return nil, errors.New("from must not be greater than to")
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	earliest, latest := l.offsetRange()
+	if to > latest {
+		return nil, ErrFutureOffset
+	}
+
+	if from < earliest {
+		return nil, ErrOutOfRange
+	}
+
+	// collect the records first to size the buffer
+	records := make([]Record, 0, to-from+1)
+	size := 0
+	for offset := from; offset <= to; offset++ {
+		r, err := l.read(ctx, offset)
+		if skippable(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, r)
+		size += len(r.Data)
+	}
+
+	buf := make([]byte, 0, size)
+	for _, r := range records {
+		buf = append(buf, r.Data...)
+	}
+
+	return buf, nil
+}
+
+// forwardBatchSize is the number of records read at once by ForwardTo
+const forwardBatchSize = 64
+
+// ForwardTo writes the data of all records from the specified offset up to the
+// latest record in the log to dst, e.g. to move records from a small hot log
+// into a large cold log. The records in dst are assigned new offsets and
+// creation timestamps. The offset of the last forwarded record is returned so
+// that the caller can resume forwarding from the next offset. If no record was
+// forwarded, InvalidOffset is returned. Reaching the end of the log is not an
+// error.
+//
+// Records are read in batches and the read lock is not held while writing to
+// dst. If an error occurs, the offset of the last forwarded record and the
+// error is returned.
+//
+// Safe for concurrent use.
+func (l *Log) ForwardTo(ctx context.Context, dst *Log, from Offset) (Offset, error) {
+	if dst == nil {
+		return InvalidOffset, errors.New("destination log must not be nil")
+	}
+
+	last := InvalidOffset
+	batch := make([]Record, forwardBatchSize)
+	for {
+		count, err := l.ReadBatch(ctx, from, batch)
+
+		for _, r := range batch[:count] {
+			if _, werr := dst.Write(ctx, r.Data); werr != nil {
+				return last, fmt.Errorf("forward offset %d: %w", r.Metadata.Offset, werr)
+			}
+			last = r.Metadata.Offset
+			from = last + 1
+		}
+
+		if err != nil {
+			if isEndOfLog(err) {
+				return last, nil
+			}
+			return last, err
+		}
+	}
+}
+
+// Age returns the age of the record at the specified offset, i.e. the duration
+// since the record was created based on the clock of the log. The offset is
+// validated like in Read. If an error occurs, 0 and the error is returned.
+//
+// Safe for concurrent use.
+func (l *Log) Age(ctx context.Context, offset Offset) (time.Duration, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.conf.noTimestamps {
+		return 0, errNoTimestamps
+	}
+
+	r, err := l.read(ctx, offset)
+	if err != nil {
+		return 0, err
+	}
+
+	return l.clock.Since(r.Metadata.Created), nil
+}
+
+// ReadBatch reads multiple records into batch starting at the specified offset.
+// The number of records read into batch and the error, if any, is returned.
+//
+// ReadBatch will read at most len(batch) records, always starting at batch
+// index 0. ReadBatch stops reading at the end of the log, indicated by
+// ErrFutureOffset, or io.EOF if the log was created with WithEOFSemantics().
+// offset might be OffsetEarliest or OffsetLatest.
+//
+// The caller must expect partial batch results and must not read more records
+// from batch than indicated by the returned number of records. If the log was
+// created with WithMaxReadBatch(), at most this number of records is read per
+// call and the caller must call ReadBatch again to read more records. See the
+// example for how to use this API.
+//
+// If the log was created with WithReadYield(), the records in batch are not
+// guaranteed to be read from the same point-in-time view of the log. If
+// records are purged while the lock is released, the records read so far and
+// ErrOutOfRange is returned.
+//
+// If batch has length 0, no records are read but offset is validated like in
+// Read, i.e. 0 and no error is returned if the record at offset is readable.
+// Otherwise 0 and the error, e.g. ErrOutOfRange or ErrFutureOffset, is
+// returned.
+//
+// Safe for concurrent use.
+func (l *Log) ReadBatch(ctx context.Context, offset Offset, batch []Record) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	offset = l.resolveOffset(offset)
+
+	if len(batch) == 0 {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		_, err := l.readableSegment(offset)
+		return 0, l.eofError(err)
+	}
+
+	count, err := l.readBatch(ctx, offset, batch, nil)
+	return count, l.eofError(err)
+}
+
+// ReadBatchLatest reads the latest n available records into batch in ascending
+// offset order, i.e. the latest record is at batch[count-1]. The number of
+// records read into batch and the error, if any, is returned. Contrary to
+// ReadBatch, the caller does not need to know the offset range of the log.
+//
+// At most len(batch) records are read, always starting at batch index 0. If
+// fewer than n records are available, all available records are read. If the
+// log was created with WithMaxReadBatch(), at most this number of latest
+// records is read. Records which are not readable, e.g. compacted or expired
+// records or unwritten reserved offsets, are skipped, i.e. fewer than n records
+// might be read. If
+// the log is empty, 0 and no error is returned.
+//
+// Safe for concurrent use.
+func (l *Log) ReadBatchLatest(ctx context.Context, n int, batch []Record) (int, error) {
+	if n <= 0 {
+		return 0, errors.New("n must be greater than 0")
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	earliest, latest := l.offsetRange()
+	if earliest == InvalidOffset {
+		return 0, nil
+	}
+
+	count := n
+	if len(batch) < count {
+		count = len(batch)
+	}
+	if max := l.conf.maxReadBatch; max > 0 && max < count {
+		count = max
+	}
+	if available := int(latest - earliest + 1); available < count {
+		count = available
+	}
+
+	return l.readAvailable(ctx, latest-Offset(count)+1, batch[:count])
+}
+
+// Head returns the oldest n available records in ascending offset order, e.g.
+// for debug endpoints. If fewer than n records are available, all available
+// records are returned. Records which are not readable, e.g. compacted records
+// or unwritten reserved offsets, are skipped. If the log was created with WithMaxReadBatch(), at most
+// this number of records is returned. If the log is empty, an empty slice and
+// no error is returned. n must be greater than 0.
+//
+// Safe for concurrent use.
+func (l *Log) Head(ctx context.Context, n int) ([]Record, error) {
+	return l.readEnd(ctx, n, false)
+}
+
+// Tail returns the latest n available records in ascending offset order, i.e.
+// the latest record is the last element, e.g. for dashboards showing the last n
+// events. If fewer than n records are available, all available records are
+// returned. The latest n offsets are read, i.e. fewer records are returned if
+// some of them are not readable, e.g. compacted records or unwritten reserved
+// offsets. If the log was created with WithMaxReadBatch(), at most this number
+// of records is returned. If the log is empty, an empty slice and no error is
+// returned. n must be greater than 0.
+//
+// Safe for concurrent use.
+func (l *Log) Tail(ctx context.Context, n int) ([]Record, error) {
+	return l.readEnd(ctx, n, true)
+}
+
+// readEnd reads up to n records from the beginning or, if latest is true, the
+// end of the log under a single read lock
+func (l *Log) readEnd(ctx context.Context, n int, latest bool) ([]Record, error) {
+	if n <= 0 {
+		return nil, errors.New("n must be greater than 0")
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	earliest, last := l.offsetRange()
+	if earliest == InvalidOffset {
+		return []Record{}, nil
+	}
+
+	count := n
+	if available := int(last - earliest + 1); available < count {
+		count = available
+	}
+	if max := l.conf.maxReadBatch; max > 0 && max < count {
+		count = max
+	}
+
+	start := earliest
+	if latest {
+		start = last - Offset(count) + 1
+	}
+
+	batch := make([]Record, count)
+	count, err := l.readAvailable(ctx, start, batch)
+	if err != nil {
+		return nil, err
+	}
+	return batch[:count], nil
+}
+
+// readAvailable reads the readable records starting at offset into batch until
+// batch is full or the end of the log is reached. Contrary to readBatch,
+// unwritten reserved offsets are skipped like compacted or expired records and
+// the lock is not released. The number of records read is returned. Must be
+// protected with a read lock by the caller.
+func (l *Log) readAvailable(ctx context.Context, offset Offset, batch []Record) (int, error) {
+	var count int
+	for ; count < len(batch) && offset < l.offset; offset++ {
+		if count%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+
+		r, err := l.read(ctx, offset)
+		if skippable(err) || errors.Is(err, ErrNoRecord) {
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+
+		batch[count] = r
+		count++
+	}
+	return count, nil
+}
+
+// ReadBatchBytes reads records starting at the specified offset until adding
+// the next record would exceed maxBytes of record data, e.g. to batch records
+// for a downstream with a payload budget. The records and the offset of the
+// next record to read are returned. At least one record is returned if
+// available, even if its data alone exceeds maxBytes. maxBytes must be greater
+// than 0.
+//
+// If the log was created with WithMaxReadBatch(), at most this number of
+// records is returned. Errors are returned like in ReadBatch, i.e. if the end
+// of the log is reached, the records read so far and ErrFutureOffset (or
+// io.EOF, see WithEOFSemantics()) is returned.
+//
+// Safe for concurrent use.
+func (l *Log) ReadBatchBytes(ctx context.Context, start Offset, maxBytes int) ([]Record, Offset, error) {
+	if maxBytes <= 0 {
+		return nil, start, errors.New("max bytes must be greater than 0")
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var (
+		records []Record
+		size    int
+	)
+
+	offset := start
+	for {
+		if max := l.conf.maxReadBatch; max > 0 && len(records) == max {
+			return records, offset, nil
+		}
+
+		// return promptly on cancellation during large batches
+		if len(records)%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return records, offset, ctx.Err()
+		}
+
+		r, err := l.read(ctx, offset)
+		if skippable(err) {
+			offset++
+			continue
+		}
+		if err != nil {
+			return records, offset, l.eofError(err)
+		}
+
+		if len(records) > 0 && size+len(r.Data) > maxBytes {
+			return records, offset, nil
+		}
+
+		records = append(records, r)
+		size += len(r.Data)
+		offset++
+	}
+}
+
+// ReadRange reads the records created in the half-open time interval [from,to)
+// into batch, e.g. to replay a window of records. The number of records read
+// into batch and the error, if any, is returned. Reaching the end of the
+// interval is not an error. If batch is full before the end of the interval,
+// the caller continues with ReadUntilTime() starting after the last record read.
+// from must be before to.
+//
+// The first record is found with a binary search like OffsetForTime(), i.e.
+// record timestamps are required to be non-decreasing. Otherwise ReadRange
+// behaves like ReadBatch, i.e. the caller must expect partial batch results and
+// ErrFutureOffset (or io.EOF) at the end of the log.
+//
+// Safe for concurrent use.
+func (l *Log) ReadRange(ctx context.Context, from, to time.Time, batch []Record) (int, error) {
+	if !from.Before(to) {
+		return 0, errors.New("from must be before to")
+	}
+
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.conf.noTimestamps {
+		return 0, errNoTimestamps
+	}
+
+	start, err := l.offsetForTime(ctx, from)
+	if err != nil {
+		return 0, l.eofError(err)
+	}
+
+	count, err := l.readBatch(ctx, start, batch, func(r Record) bool {
+		return !r.Metadata.Created.Before(to)
+	})
+	return count, l.eofError(err)
+}
+
+// ReadUntilTime reads multiple records into batch starting at the specified
+// offset, stopping early at the first record created at or after until. The
+// number of records read into batch and the error, if any, is returned.
+// Reaching the time boundary is not an error.
+//
+// Otherwise ReadUntilTime behaves like ReadBatch, i.e. the caller must expect
+// partial batch results and ErrFutureOffset (or io.EOF) at the end of the log.
+//
+// Safe for concurrent use.
+func (l *Log) ReadUntilTime(ctx context.Context, from Offset, until time.Time, batch []Record) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	count, err := l.readBatch(ctx, from, batch, func(r Record) bool {
+		return !r.Metadata.Created.Before(until)
+	})
+	return count, l.eofError(err)
+}
+
+// eofError returns io.EOF if err is ErrFutureOffset and the log was created
+// with WithEOFSemantics(). Otherwise err is returned.
+func (l *Log) eofError(err error) error {
+	if l.conf.eof && errors.Is(err, ErrFutureOffset) {
+		return io.EOF
+	}
+	return err
+}
+
+// isEndOfLog returns true if err indicates the end of the log, i.e. is
+// ErrFutureOffset or io.EOF (see WithEOFSemantics)
+func isEndOfLog(err error) bool {
+	return errors.Is(err, ErrFutureOffset) || errors.Is(err, io.EOF)
+}
+
+// ctxCheckInterval is the number of records after which batch reads check for
+// context cancellation
+const ctxCheckInterval = 64
+
+// readBatch reads records into batch starting at offset. If stop is not nil,
+// reading stops before the first record for which stop returns true. Must be
+// protected with a read lock by the caller, which might be temporarily released
+// (see yield).
+func (l *Log) readBatch(ctx context.Context, offset Offset, batch []Record, stop func(Record) bool) (int, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	if max := l.conf.maxReadBatch; max > 0 && len(batch) > max {
+		batch = batch[:max]
+	}
+
+	for i := 0; i < len(batch); i++ {
+		// return promptly on cancellation during large batches
+		if i%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return i, ctx.Err()
+		}
+
+		yielded := l.yield(i)
+
+		// read validates offset against the current log range
+		r, err := l.read(ctx, offset)
+		for skippable(err) {
+			offset++
+			r, err = l.read(ctx, offset)
+		}
+		if err != nil {
+			// purged while yielding, return what we have
+			if yielded && errors.Is(err, ErrOutOfRange) {
+				return i, err
+			}
+
+			// invalid start offset or empty log
+			if errors.Is(err, ErrOutOfRange) {
+				return 0, err
+			}
+
+			// return what we have
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return i, err
+			}
+
+			// end of log
+			if errors.Is(err, ErrFutureOffset) {
+				return i, ErrFutureOffset
+			}
+
+			return i, err
+		}
+
+		if stop != nil && stop(r) {
+			return i, nil
+		}
+
+		batch[i] = r
+		offset++
+	}
+
+	return len(batch), nil
+}
+
+// yield briefly releases and re-acquires the read lock every readYield records
+// to let pending writers make progress during long reads. It returns true if
+// the lock was released, in which case the caller must re-validate offsets as
+// the log might have been modified. Must be protected with a read lock by the
+// caller.
+func (l *Log) yield(records int) bool {
+	if l.conf.readYield == 0 || records == 0 || records%l.conf.readYield != 0 {
+		return false
+	}
+
+	l.mu.RUnlock()
+	l.mu.RLock()
+	return true
+}
+
+func (l *Log) read(ctx context.Context, offset Offset) (Record, error) {
+	if ctx.Err() != nil {
+		return Record{}, ctx.Err()
+	}
+
+	if l.fault != nil {
+		if err := l.fault(FaultOpRead, offset); err != nil {
+			return Record{}, err
+		}
+	}
+
+	s, err := l.readableSegment(offset)
+	if err != nil {
+		return Record{}, err
+	}
+
+	r, err := s.read(ctx, offset)
+	if err != nil {
+		return Record{}, err
+	}
+
+	// written records always have data
+	if r.Data == nil {
+		return Record{}, ErrCompacted
+	}
+
+	if r.expired(l.clock.Now()) {
+		return Record{}, errExpired
+	}
+
+	if r.Metadata.ext().Chunk > 0 {
+		return Record{}, ErrChunkContinuation
+	}
+
+	if r, err = l.decode(r); err != nil {
+		return Record{}, err
+	}
+
+	if r.Metadata.ext().Chunks > 1 {
+		r, err = assemble(r, func(offset Offset) (Record, error) {
+			s, err := l.getSegment(offset)
+			if err != nil {
+				return Record{}, err
+			}
+
+			c, err := s.read(ctx, offset)
+			if err != nil {
+				return Record{}, err
+			}
+			return l.decode(c)
+		})
+		if err != nil {
+			return Record{}, err
+		}
+	}
+
+	atomic.AddUint64(&l.reads, 1)
+
+	if l.readIntercept != nil {
+		return l.readIntercept(ctx, r.deepCopy())
+	}
+
+	return r.deepCopy(), nil
+}
+
+// decode returns r with decrypted data and verifies its checksum if enabled
+func (l *Log) decode(r Record) (Record, error) {
+	r, err := decrypt(l.keys, r)
+	if err != nil {
+		return Record{}, err
+	}
+
+	if l.conf.verifyChecksums && checksum(r.Data) != r.Metadata.Checksum {
+		return Record{}, fmt.Errorf("record at offset %d: %w", r.Metadata.Offset, ErrChecksumMismatch)
+	}
+
+	return r, nil
+}
+
+// skippable returns true if the read error err indicates a record which was
+// removed by compaction, expired, is a continuation chunk or was filtered by a
+// ReadInterceptor, which batch reads and streams skip
+func skippable(err error) bool {
+	return errors.Is(err, ErrCompacted) || errors.Is(err, errExpired) || errors.Is(err, ErrChunkContinuation) || errors.Is(err, ErrFiltered)
+}
+
+// readableSegment returns the segment of the record at offset. An error is
+// returned if the record is not readable, i.e. the offset is in the future,
+// invalid, purged or reserved but not written yet. Must be protected with a
+// lock by the caller.
+func (l *Log) readableSegment(offset Offset) (*segment, error) {
+	if offset >= l.offset {
+		return nil, ErrFutureOffset
+	}
+
+	if offset < l.floor {
+		return nil, ErrOutOfRange
+	}
+
+	s, err := l.getSegment(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := l.reserved[offset]; ok {
+		return nil, ErrNoRecord
+	}
+
+	return s, nil
+}
+
+// VerifyContiguous verifies that every offset in the closed interval [from,to]
+// resolves to a record. If so, InvalidOffset and true is returned.
+// Otherwise the first missing offset and false is returned. ErrOutOfRange and
+// ErrFutureOffset are returned if the interval is not within the available
+// offsets of the log.
+//
+// Safe for concurrent use.
+func (l *Log) VerifyContiguous(ctx context.Context, from, to Offset) (firstGap Offset, ok bool, err error) {
+	if from > to {
+		return InvalidOffset, false, errors.New("from must not be greater than to")
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	earliest, latest := l.offsetRange()
+	if to > latest {
+		return InvalidOffset, false, ErrFutureOffset
+	}
+
+	if from < earliest {
+		return InvalidOffset, false, ErrOutOfRange
+	}
+
+	for offset := from; offset <= to; offset++ {
+		if ctx.Err() != nil {
+			return InvalidOffset, false, ctx.Err()
+		}
+
+		if l.yield(int(offset - from)) {
+			// purged while yielding
+			if earliest, _ = l.offsetRange(); offset < earliest {
+				return InvalidOffset, false, ErrOutOfRange
+			}
+		}
+
+		s, err := l.getSegment(offset)
+		if err != nil {
+			return offset, false, nil
+		}
+
+		if _, ok := l.reserved[offset]; ok {
+			return offset, false, nil
+		}
+
+		r, err := s.read(ctx, offset)
+		if err != nil || r.Metadata.Offset != offset {
+			return offset, false, nil
+		}
+	}
+
+	return InvalidOffset, true, nil
+}
+
+// WriteRate returns the number of writes per second over the sliding window
+// configured with WithRateTracker(), based on the clock of the log. If rate
+// tracking is not enabled, 0 is returned.
+//
+// Safe for concurrent use.
+func (l *Log) WriteRate() float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.rate == nil {
+		return 0
+	}
+
+	return l.rate.rate(l.clock.Now())
+}
+
+// Warm sequentially touches all records in the log to pull them into CPU
+// caches and memory, e.g. after a cold start. It trades a one-time cost,
+// holding the read lock for the whole log, for steadier read latency of
+// subsequent reads.
+//
+// Safe for concurrent use.
+func (l *Log) Warm(ctx context.Context) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	segments := make([]*segment, 0, len(l.history)+1)
+	segments = append(segments, l.history...)
+	segments = append(segments, l.active)
+
+	var sum byte
+	for _, s := range segments {
+		records, err := s.records()
+		if err != nil {
+			return err
+		}
+
+		for _, r := range records {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// one byte per cache line
+			for i := 0; i < len(r.Data); i += 64 {
+				sum += r.Data[i]
+			}
+		}
+	}
+
+	runtime.KeepAlive(sum)
+	return nil
+}
+
+// TrimMemory releases the unused preallocated memory of the active segment if
+// less than half of the segment is used, e.g. to reclaim memory after a write
+// burst in long-running processes. If the active segment is more than half
+// full, TrimMemory does nothing. Subsequent writes allocate memory for the
+// active segment on demand until it is full. If the log was created with
+// WithSegmentStore(), TrimMemory does nothing.
+//
+// Safe for concurrent use.
+func (l *Log) TrimMemory(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active.trim()
+	return nil
+}
+
+// Digest returns a SHA-256 digest over all readable records in the log in
+// offset order, covering the offset, creation time and decrypted data of each
+// record. Logs with identical readable records produce identical digests, e.g.
+// to verify that replicas are in sync without transferring the records.
+//
+// Truncated records, records removed by compaction, expired records (see
+// WriteTTL()) and unwritten reserved offsets are not covered. Chunks of records
+// written with WithChunking() are covered individually. A ReadInterceptor is not
+// applied.
+//
+// Safe for concurrent use.
+func (l *Log) Digest(ctx context.Context) ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	segments := make([]*segment, 0, len(l.history)+1)
+	segments = append(segments, l.history...)
+	segments = append(segments, l.active)
+
+	now := l.clock.Now()
+	h := sha256.New()
+	var buf [24]byte
+	for _, s := range segments {
+		records, err := s.records()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range records {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			if r.Metadata.Offset < l.floor {
+				// truncated
+				continue
+			}
+
+			// compacted, unwritten reserved or expired
+			if r.Data == nil || r.expired(now) {
+				continue
+			}
+
+			// encrypted data differs between logs
+			r, err := decrypt(l.keys, r)
+			if err != nil {
+				return nil, err
+			}
+
+			// length-prefix data to separate records
+			binary.BigEndian.PutUint64(buf[0:], uint64(r.Metadata.Offset))
+			binary.BigEndian.PutUint64(buf[8:], uint64(r.Metadata.Created.UnixNano()))
+			binary.BigEndian.PutUint64(buf[16:], uint64(len(r.Data)))
+			h.Write(buf[:])
+			h.Write(r.Data)
+		}
+	}
+
+	return h.Sum(nil), nil
+}
+
+// ActiveFillRatio returns the fill ratio of the active segment between 0 (empty)
+// and 1 (full), i.e. the ratio of written records to the segment size.
+// Unwritten reserved offsets, see Reserve(), are not counted.
+//
+// Safe for concurrent use.
+func (l *Log) ActiveFillRatio(_ context.Context) float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.activeFillRatio()
+}
+
+// activeFillRatio returns the fill ratio of the active segment, see
+// ActiveFillRatio(). Must be protected with a read lock by the caller.
+func (l *Log) activeFillRatio() float64 {
+	return float64(l.active.count) / float64(l.active.size)
+}
+
+// CurrentSequence returns the global sequence number which is assigned to the
+// next record written to the log. If the log was not created with
+// WithGlobalSequence(), 0 is returned.
+//
+// Safe for concurrent use.
+func (l *Log) CurrentSequence() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.seq
+}
+
+// Name returns the name of the log set with WithName(). If no name was set, an
+// empty string is returned.
+func (l *Log) Name() string {
+	return l.conf.name
+}
+
+// Value returns the value associated with key set with WithUserValue(), or nil
+// if no value is associated with key.
+//
+// Safe for concurrent use.
+func (l *Log) Value(key interface{}) interface{} {
+	return l.values[key]
+}
+
+// Stats are statistics of a log
+type Stats struct {
+	// Name is the name of the log, see WithName()
+	Name string
+	// Records is the number of available records, see Len()
+	Records int
+	// Bytes is the data (payload) size of the records retained in memory
+	Bytes int
+	// Purges is the number of purges since the log was created
+	Purges uint64
+	// SegmentsCreated is the number of segments created since the log was
+	// created, including the initial active segment
+	SegmentsCreated uint64
+	// SegmentsPurged is the number of segments purged since the log was
+	// created. A high churn rate compared to the write rate indicates that the
+	// segment size is too small, see WithMaxSegmentSize().
+	SegmentsPurged uint64
+	// Writes is the number of records written since the log was created,
+	// including continuation chunks, see WithChunking(). Records restored from
+	// the write-ahead log are not included, see WithPersistence().
+	Writes uint64
+	// Reads is the number of records read from the log since it was created.
+	// Reads from snapshots and read replicas are not included.
+	Reads uint64
+	// Earliest is the earliest available record offset, see Range()
+	Earliest Offset
+	// Latest is the latest available record offset, see Range()
+	Latest Offset
+	// ActiveFillRatio is the fill ratio of the active segment, see
+	// ActiveFillRatio()
+	ActiveFillRatio float64
+	// LastWrite is the creation time of the last written record, zero if no
+	// record was written or timestamps are disabled
+	LastWrite time.Time
+}
+
+// Stats returns statistics of the log, e.g. for monitoring.
+//
+// Safe for concurrent use.
+func (l *Log) Stats(_ context.Context) Stats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	stats := Stats{
+		Name:            l.conf.name,
+		Bytes:           l.bytes,
+		Purges:          l.purges,
+		SegmentsCreated: l.created,
+		SegmentsPurged:  l.destroyed,
+		Writes:          l.writes,
+		Reads:           atomic.LoadUint64(&l.reads),
+		ActiveFillRatio: l.activeFillRatio(),
+		LastWrite:       l.lastWrite,
+	}
+
+	stats.Records = l.len()
+	stats.Earliest, stats.Latest = l.offsetRange()
+
+	return stats
+}
+
+// recordOverhead is the approximate in-memory size of a record without data
+var recordOverhead = int(unsafe.Sizeof(Record{}))
+
+// Len returns the number of available records in the log. Compacted records,
+// unwritten reserved offsets and truncated records are not counted. Records
+// expired with WriteTTL() are counted until they are purged, see Expire(), and
+// chunks of records split with WithChunking() are counted individually.
+//
+// Safe for concurrent use.
+func (l *Log) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.len()
+}
+
+// SizeBytes returns the approximate memory size of the records retained in
+// memory in bytes, i.e. the record data plus a fixed overhead per record, e.g.
+// to expose the memory footprint of the log. Contrary to Len, truncated records
+// which are retained in memory until their segment is purged are included, see
+// Truncate(). Metadata such as keys, IDs and attributes and the capacity of
+// segments is not included.
+//
+// Safe for concurrent use.
+func (l *Log) SizeBytes() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.bytes + l.records*recordOverhead
+}
+
+// len returns the number of available records, see Len(). Must be protected
+// with a read lock by the caller.
+func (l *Log) len() int {
+	return l.records - l.truncated
+}
+
+// Purged returns true if records have been purged from the log at least once,
+// i.e. a slow reader might have missed records.
+//
+// Safe for concurrent use.
+func (l *Log) Purged() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.purged
+}
+
+// WaitEmpty blocks until the log is empty, i.e. Range returns InvalidOffset for
+// earliest and latest, or ctx is cancelled. WaitEmpty is notified on log
+// modifications and does not poll. Note that WaitEmpty might never return if
+// the log does not become empty, e.g. due to concurrent writes, unless ctx is
+// cancelled.
+//
+// If the log was created with WithDefaultTimeout() and ctx has no deadline,
+// WaitEmpty returns context.DeadlineExceeded after the default timeout.
+//
+// Safe for concurrent use.
+func (l *Log) WaitEmpty(ctx context.Context) error {
+	ctx, cancel := l.withDefaultTimeout(ctx)
+	defer cancel()
+
+	for {
+		// subscribe before checking to not miss modifications
+		changed := l.subscribe()
+
+		l.mu.RLock()
+		earliest, _ := l.offsetRange()
+		l.mu.RUnlock()
+
+		if earliest == InvalidOffset {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Close closes the log for writes, i.e. subsequent writes return ErrClosed, and
+// stops all streams created from the log with ErrClosed. Records can still be
+// read from a closed log. If the log was created with WithPersistence(), the
+// write-ahead log is closed and the error, if any, is returned. Closing a
+// closed log has no effect.
+//
+// Safe for concurrent use.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+	l.notify()
+
+	if l.wal != nil {
+		return l.wal.close()
+	}
+	return nil
+}
+
+// isClosed returns true if the log was closed with Close()
+func (l *Log) isClosed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.closed
+}
+
+// withDefaultTimeout returns ctx with the timeout configured with
+// WithDefaultTimeout() if ctx has no deadline. Otherwise ctx is returned
+// unmodified.
+func (l *Log) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if l.conf.defaultTimeout == 0 {
+		return ctx, func() {}
+	}
+
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, l.conf.defaultTimeout)
+}
+
+// subscribe returns a channel which is closed on the next log modification
+func (l *Log) subscribe() <-chan struct{} {
+	l.notifyMu.Lock()
+	defer l.notifyMu.Unlock()
+
+	if l.changed == nil {
+		l.changed = make(chan struct{})
+	}
+	return l.changed
+}
+
+// notify wakes up all goroutines waiting for log modifications
+func (l *Log) notify() {
+	l.notifyMu.Lock()
+	defer l.notifyMu.Unlock()
+
+	if l.changed != nil {
+		close(l.changed)
+		l.changed = nil
+	}
+}
+
+// OffsetForTime returns the offset of the earliest available record created at
+// or after t. If no such record exists, InvalidOffset and ErrFutureOffset is
+// returned. If t is before the creation time of the earliest available record,
+// the earliest offset is returned. Records with equal creation timestamps, e.g.
+// written within the same clock tick, are ordered by offset, i.e. the lowest
+// offset of these records is returned.
+//
+// OffsetForTime performs a binary search over the log and requires record
+// timestamps to be non-decreasing, see WithMonotonicTimestamps(). If the log
+// was created with WithTimeIndex(), the search is narrowed to the records in the
+// time bucket of t.
+//
+// Safe for concurrent use.
+func (l *Log) OffsetForTime(ctx context.Context, t time.Time) (Offset, error) {
+	if ctx.Err() != nil {
+		return InvalidOffset, ctx.Err()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.conf.noTimestamps {
+		return InvalidOffset, errNoTimestamps
+	}
+
+	return l.offsetForTime(ctx, t)
+}
+
+// offsetForTime returns the offset of the earliest available record created at
+// or after t, see OffsetForTime(). Must be protected with a read lock by the
+// caller.
+func (l *Log) offsetForTime(ctx context.Context, t time.Time) (Offset, error) {
+	earliest, latest := l.offsetRange()
+	if earliest == InvalidOffset {
+		return InvalidOffset, ErrFutureOffset
+	}
+
+	// search the half-open interval [lo,hi)
+	lo, hi := earliest, latest+1
+	if l.timeIdx != nil {
+		ilo, ihi := l.timeIdx.bounds(t)
+		if ilo > lo {
+			lo = ilo
+		}
+		if ihi != InvalidOffset && ihi < hi {
+			hi = ihi
+		}
+	}
+
+	// lower bound search: the offset breaks ties between records with equal
+	// timestamps as the search converges to the lowest matching offset
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		s, err := l.getSegment(mid)
+		if err != nil {
+			return InvalidOffset, err
+		}
+
+		r, err := s.read(ctx, mid)
+		if err != nil {
+			return InvalidOffset, err
+		}
+
+		if r.Metadata.Created.Before(t) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	if lo > latest {
+		return InvalidOffset, ErrFutureOffset
+	}
+
+	return lo, nil
+}
+
+// Range returns the earliest and latest available record offset in the log. If
+// the log is empty, InvalidOffset for both return values is returned.
+// If the log has been purged one or more times, earliest points to the oldest
+// available record offset in the log, i.e. not the configured start offset.
+//
+// Note that these values might have changed after retrieval, e.g. due to
+// concurrent writes.
+//
+// Safe for concurrent use.
+func (l *Log) Range(_ context.Context) (earliest, latest Offset) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	earliest, latest = l.offsetRange()
+	return
+}
+
+// Recover returns the earliest available record offset in the log, e.g. for a
+// slow reader to resume reading at the earliest record after a read failed with
+// ErrOutOfRange because the records were purged. Records between the offset
+// of the failed read and the returned offset are lost for the reader. If the
+// log is empty, InvalidOffset and ErrFutureOffset is returned.
+//
+// Safe for concurrent use.
+func (l *Log) Recover(ctx context.Context) (Offset, error) {
+	if ctx.Err() != nil {
+		return InvalidOffset, ctx.Err()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	earliest, _ := l.offsetRange()
+	if earliest == InvalidOffset {
+		return InvalidOffset, ErrFutureOffset
+	}
+
+	return earliest, nil
+}
+
+// SegmentInfo describes a segment of the log
+type SegmentInfo struct {
+	// Start is the first offset of the segment
+	Start Offset
+	// End is the last written offset of the segment, InvalidOffset if the
+	// segment is empty
+	End Offset
+	// Sealed is true if the segment is read-only, i.e. its records do not
+	// change until the segment is purged
+	Sealed bool
+}
+
+// Segments returns the retained history segments, oldest first, followed by
+// the active segment, e.g. to read sealed segments in parallel. Note that
+// these values might have changed after retrieval, e.g. due to concurrent
+// writes and purges.
+//
+// Safe for concurrent use.
+func (l *Log) Segments(_ context.Context) []SegmentInfo {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	segments := make([]SegmentInfo, 0, len(l.history)+1)
+	for _, s := range l.history {
+		segments = append(segments, SegmentInfo{
+			Start:  s.start,
+			End:    s.currentOffset(),
+			Sealed: s.sealed,
+		})
+	}
+
+	return append(segments, SegmentInfo{
+		Start:  l.active.start,
+		End:    l.active.currentOffset(),
+		Sealed: l.active.sealed,
+	})
+}
+
+// Validate checks the internal invariants of the log, i.e. segment ordering,
+// offset contiguity across and within segments, consistency of the next write
+// offset and the size limits of segments and records. The first violation is
+// returned as a descriptive error. A log modified only through its methods
+// always passes validation.
+//
+// Safe for concurrent use.
+func (l *Log) Validate() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.active == nil {
+		return errors.New("no active segment")
+	}
+
+	if l.active.sealed {
+		return fmt.Errorf("active segment at offset %d is sealed", l.active.start)
+	}
+
+	if len(l.history) == 0 && !l.purged && l.active.start != l.conf.startOffset {
+		return fmt.Errorf("active segment starts at offset %d, want start offset %d", l.active.start, l.conf.startOffset)
+	}
+
+	segments := append(append([]*segment(nil), l.history...), l.active)
+	for i, s := range segments {
+		if s.size != l.conf.segmentSize {
+			return fmt.Errorf("segment at offset %d has size %d, want %d", s.start, s.size, l.conf.segmentSize)
+		}
+
+		if err := s.validate(); err != nil {
+			return err
+		}
+
+		records, err := s.records()
+		if err != nil {
+			return err
+		}
+
+		for _, r := range records {
+			size := len(r.Data)
+			if r.Metadata.ext().KeyID != "" {
+				size -= encryptionOverhead
+			}
+
+			if size > l.conf.maxRecordSize {
+				return fmt.Errorf("record at offset %d: %w", r.Metadata.Offset, ErrRecordTooLarge)
+			}
+
+			if _, ok := l.reserved[r.Metadata.Offset]; !ok && len(r.Data) == 0 && l.compactor == nil {
+				return fmt.Errorf("record at offset %d has no data", r.Metadata.Offset)
+			}
+		}
+
+		if s == l.active {
+			break
+		}
+
+		if !s.sealed {
+			return fmt.Errorf("history segment at offset %d is not sealed", s.start)
+		}
+
+		if s.len() != s.size {
+			return fmt.Errorf("history segment at offset %d is not full", s.start)
+		}
+
+		if next := segments[i+1]; next.start != s.start+Offset(s.len()) {
+			return fmt.Errorf("segment at offset %d does not follow segment at offset %d", next.start, s.start)
+		}
+	}
+
+	if want := l.active.start + Offset(l.active.len()); l.offset != want {
+		return fmt.Errorf("next write offset is %d, want %d", l.offset, want)
+	}
+
+	return nil
+}
+
+// OldestTime returns the creation timestamp of the earliest written record in
+// the log. Unwritten reserved offsets, see Reserve(), are skipped. If the log is
+// empty or only contains unwritten reserved offsets, a zero time and false is
+// returned.
+//
+// Safe for concurrent use.
+func (l *Log) OldestTime(ctx context.Context) (time.Time, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	earliest, latest := l.offsetRange()
+	if earliest == InvalidOffset {
+		return time.Time{}, false
+	}
+
+	for offset := earliest; offset <= latest; offset++ {
+		if _, ok := l.reserved[offset]; ok {
+			continue
+		}
+
+		s, err := l.getSegment(offset)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		r, err := s.read(ctx, offset)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		return r.Metadata.Created, true
+	}
+
+	return time.Time{}, false
+}
+
+// LastWriteTime returns the creation timestamp of the latest written record in
+// the log without copying the record, e.g. to detect stale logs. Unwritten
+// reserved offsets, see Reserve(), are skipped. If the log is empty or only
+// contains unwritten reserved offsets, a zero time and false is returned.
+//
+// Safe for concurrent use.
+func (l *Log) LastWriteTime(_ context.Context) (time.Time, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// the latest record is in the active segment unless it only contains
+	// unwritten reserved offsets
+	for i := len(l.history); i >= 0; i-- {
+		s := l.active
+		if i < len(l.history) {
+			s = l.history[i]
+		}
+
+		for j := s.len() - 1; j >= 0; j-- {
+			r, err := s.store.ReadAt(j)
+			if err != nil || r.Metadata.Offset < l.floor {
+				return time.Time{}, false
+			}
+
+			if _, ok := l.reserved[r.Metadata.Offset]; ok {
+				continue
+			}
+			return r.Metadata.Created, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// offsetRange returns the earliest and latest available record offset in the
+// log. If the log is empty, InvalidOffset for both return values is returned.
+// If the log has been purged one or more times, earliest points to the oldest
+// available record offset in the log, i.e. not the configured start offset.
+// Must be protected with a lock by the caller.
+func (l *Log) offsetRange() (Offset, Offset) {
+	if len(l.history) == 0 {
+		// empty log
+		if l.active.currentOffset() == InvalidOffset {
+			return InvalidOffset, InvalidOffset
+		}
+
+		// no purge since start or all history expired
+		return l.maxFloor(l.active.start), l.active.currentOffset()
+	}
+
+	return l.maxFloor(l.history[0].start), l.active.currentOffset()
+}
+
+// resolveOffset resolves the sentinels OffsetEarliest and OffsetLatest to the
+// current offset range of the log. Other offsets are returned unchanged. Must
+// be protected with a read lock by the caller.
+func (l *Log) resolveOffset(offset Offset) Offset {
+	if offset != OffsetEarliest && offset != OffsetLatest {
+		return offset
+	}
+
+	earliest, latest := l.offsetRange()
+	switch {
+	case earliest == InvalidOffset:
+		return l.offset
+	case offset == OffsetEarliest:
+		return earliest
+	default:
+		return latest
+	}
+}
+
+// resolveStart is like resolveOffset but acquires the read lock, e.g. to resolve
+// the start offset of streams
+func (l *Log) resolveStart(offset Offset) Offset {
+	if offset != OffsetEarliest && offset != OffsetLatest {
+		return offset
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.resolveOffset(offset)
+}
+
+// maxFloor returns offset or the earliest readable offset after Truncate(),
+// whichever is greater
+func (l *Log) maxFloor(offset Offset) Offset {
+	if l.floor > offset {
+		return l.floor
+	}
+	return offset
+}
+
+// getSegment retrieves the segment for the specified offset. If the offset is
+// in the future, ErrFutureOffset will be returned. If the offset is invalid or
+// has been purged ErrOutOfRange is returned. Must be protected with a lock by
+// the caller.
+func (l *Log) getSegment(offset Offset) (*segment, error) {
+	// check if offset is within active segment
+	if offset >= l.active.start {
+		if offset <= l.active.currentOffset() {
+			return l.active, nil
+		}
+		return nil, ErrFutureOffset
+	}
+
+	if l.conf.linearSearch {
+		return l.searchHistoryLinear(offset)
+	}
+
+	// binary search history sorted by start offset for the last segment
+	// starting at or before offset
+	i := sort.Search(len(l.history), func(i int) bool {
+		return l.history[i].start > offset
+	}) - 1
+
+	if i < 0 {
+		return nil, ErrOutOfRange
+	}
+
+	history := l.history[i]
+	if offset > history.start+Offset(l.conf.segmentSize)-1 {
+		return nil, ErrOutOfRange
+	}
+	return history, nil
+}
+
+// searchHistoryLinear searches the history segments one by one for the segment
+// of the specified offset. Must be protected with a lock by the caller.
+func (l *Log) searchHistoryLinear(offset Offset) (*segment, error) {
+	for _, history := range l.history {
+		min := history.start
+		max := history.start + Offset(l.conf.segmentSize) - 1
+
+		if min <= offset && offset <= max {
+			return history, nil
+		}
+	}
+	return nil, ErrOutOfRange
+}
+
+// extendWithPolicy extends the log and handles failures according to the
+// configured ExtendFailurePolicy. Must be protected with a lock by the caller.
+func (l *Log) extendWithPolicy(ctx context.Context) error {
+	err := l.extend(ctx)
+	for i := 0; err != nil && i < l.conf.extendPolicy.retries; i++ {
+		err = l.extend(ctx)
+	}
+
+	if err != nil {
+		if l.conf.extendPolicy.panic {
+			panic(err.Error()) // abnormal program state
+		}
+		return fmt.Errorf("%w: %v", ErrExtendFailed, err)
+	}
+
+	return nil
+}
+
+// newSegment creates a segment with the configured segment size and store
+// starting at startOffset
+func (l *Log) newSegment(startOffset Offset) (*segment, error) {
+	if l.newStore == nil {
+		return newSegment(startOffset, l.conf.segmentSize)
+	}
+	return newSegmentWithStore(startOffset, l.conf.segmentSize, l.newStore)
+}
+
+// Truncate discards all records before the specified offset, e.g. to free
+// memory once consumers have checkpointed past them, independent of segment
+// rollover. Subsequent reads of truncated offsets return ErrOutOfRange. The
+// memory of segments which only contain truncated records is released
+// immediately, truncated records in the segment of offset are retained in
+// memory until the segment is purged. Truncation is not written to the
+// write-ahead log, see WithPersistence() and CompactWAL().
+//
+// Offset must not be greater than the next write offset, in which case all
+// records are discarded. If offset is before the earliest available offset,
+// Truncate does nothing.
+//
+// Safe for concurrent use.
+func (l *Log) Truncate(ctx context.Context, before Offset) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if before > l.offset {
+		return fmt.Errorf("truncate before offset %d: %w", before, ErrFutureOffset)
+	}
+
+	if before <= l.floor {
+		return nil
+	}
+
+	l.purgeWhile(ctx, func(s *segment) bool {
+		return s.currentOffset() < before
+	})
+	l.floor = before
+	l.purged = true
+	l.truncated = l.countTruncated()
+
+	// truncated records retained in memory must not be returned as duplicates
+	if l.dedupe != nil {
+		l.dedupe.evictBefore(before)
+	}
+	l.evictIDsBefore(before)
+	return nil
+}
+
+// countTruncated returns the number of records with data before the floor of
+// the log which are retained in memory. Only the oldest segment might contain
+// such records as segments with only truncated records are purged. Must be
+// protected with a lock by the caller.
+func (l *Log) countTruncated() int {
+	oldest := l.active
+	if len(l.history) > 0 {
+		oldest = l.history[0]
+	}
+
+	var count int
+	for i := 0; i < oldest.len() && oldest.start+Offset(i) < l.floor; i++ {
+		r, err := oldest.store.ReadAt(i)
+		if err == nil && r.Data != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Expire purges all records older than the retention configured with
+// WithRetention(), which is otherwise only enforced on writes, e.g. to enforce
+// the retention periodically in logs with infrequent writes. Segments in which
+// all records are expired, see WriteTTL(), are purged as well.
+//
+// Safe for concurrent use.
+func (l *Log) Expire(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conf.retention > 0 {
+		l.expire(ctx)
+	}
+
+	now := l.clock.Now()
+	l.purgeWhile(ctx, func(s *segment) bool {
+		records, err := s.records()
+		if err != nil || len(records) == 0 {
+			return false
+		}
+
+		for _, r := range records {
+			if !r.expired(now) {
+				return false
+			}
+		}
+		return true
+	})
+	return nil
+}
+
+// expire purges all segments whose latest written record is older than the
+// configured retention based on the clock of the log. Segments which only
+// contain unwritten reserved offsets are not expired. If all records are
+// expired, the active segment is replaced with an empty segment starting at the
+// next write offset. Must be protected with a lock by the caller.
+func (l *Log) expire(ctx context.Context) {
+	cutoff := l.clock.Now().Add(-l.conf.retention)
+	expired := func(s *segment) bool {
+		// unwritten reserved offsets have no creation time, i.e. the age of
+		// the segment is the age of the latest written record
+		for i := s.len() - 1; i >= 0; i-- {
+			r, err := s.store.ReadAt(i)
+			if err != nil {
+				return false
+			}
+
+			if _, ok := l.reserved[r.Metadata.Offset]; ok {
+				continue
+			}
+			return r.Metadata.Created.Before(cutoff)
+		}
+		return false
+	}
+
+	l.purgeWhile(ctx, expired)
+}
+
+// purgeWhile purges the oldest segments as long as drop returns true for the
+// segment. If all segments are dropped, the active segment is replaced with an
+// empty segment starting at the next write offset. Must be protected with a
+// lock by the caller.
+func (l *Log) purgeWhile(ctx context.Context, drop func(s *segment) bool) {
+	var n int
+	for _, s := range l.history {
+		if !drop(s) {
+			break
+		}
+		n++
+	}
+
+	if n == len(l.history) && l.active.len() > 0 && drop(l.active) {
+		seg, err := l.newSegment(l.offset)
+		if err == nil {
+			l.active.seal()
+			l.history = append(l.history, l.active)
+			l.active = seg
+			l.created++
+			n++
+		}
+	}
+
+	if n > 0 {
+		l.purge(ctx, n)
+	}
+}
+
+// extend creates a new active segment and appends the current active segment
+// to history. The old segment is sealed. If history is full, i.e. contains the
+// maximum number of history segments, purgeBatch segments are purged before. If the new
+// segment can not be created, the log is not modified. Must be protected with a
+// lock by the caller.
+func (l *Log) extend(ctx context.Context) error {
+	seg, err := l.newSegment(l.offset)
+	if err != nil {
+		return err
+	}
+
+	l.rollover(ctx, seg)
+	return nil
+}
+
+// rollover seals the active segment, appends it to history and makes the empty
+// segment seg the active segment. If history is full, purgeBatch segments are
+// purged before. Must be protected with a lock by the caller.
+func (l *Log) rollover(ctx context.Context, seg *segment) {
+	l.active.seal()
+
+	if len(l.history) >= l.conf.historySegments() {
+		l.purge(ctx, l.conf.purgeBatch)
+	}
+
+	l.history = append(l.history, l.active)
+	l.active = seg
+	l.created++
+
+	if l.compactor != nil {
+		l.compact()
+	}
+}
+
+// purge removes the n oldest segments from history. Must be protected with a
+// lock by the caller.
+func (l *Log) purge(ctx context.Context, n int) {
+	for _, s := range l.history[:n] {
+		if l.purgeFn != nil && !l.restoring {
+			records, err := s.records()
+			if err == nil && l.keys != nil {
+				records, err = l.decryptAll(records)
+			}
+			if err == nil {
+				l.purgeFn(ctx, records[:len(records):len(records)])
+			}
+		}
+
+		if l.dedupe != nil {
+			l.dedupe.evict(s)
+		}
+
+		if len(l.ids) > 0 {
+			l.evictIDs(s)
+		}
+
+		if l.compactor != nil {
+			l.compactor.evict(s)
+		}
+
+		for offset := range l.reserved {
+			if offset <= s.currentOffset() {
+				delete(l.reserved, offset)
+			}
+		}
+
+		l.bytes -= s.bytes
+		l.records -= s.count
+		if s.start < l.floor {
+			// only the oldest segment retains truncated records
+			l.truncated = 0
+		}
+
+		// purging must not fail, the store is responsible for handling close
+		// errors
+		_ = s.store.Close()
+	}
+
+	// do not retain purged segments in the backing array
+	l.history = append([]*segment(nil), l.history[n:]...)
+	l.purged = true
+	l.purges++
+	l.destroyed += uint64(n)
+
+	if l.timeIdx != nil {
+		// the active segment becomes history if all history segments are purged
+		oldest := l.active
+		if len(l.history) > 0 {
+			oldest = l.history[0]
+		}
+		if r, err := oldest.store.ReadAt(0); err == nil {
+			l.timeIdx.prune(oldest.start, r.Metadata.Created)
+		} else {
+			// all records expired
+			l.timeIdx = newTimeIndex(l.timeIdx.granularity)
+		}
+	}
 }
@@ -2,6 +2,8 @@ package memlog
 
 import (
 	"context"
+	"runtime"
+	"strconv"
 	"testing"
 )
 
@@ -43,6 +45,59 @@ func BenchmarkLog_write(b *testing.B) {
 	_ = result
 }
 
+func BenchmarkLog_WriteBatch(b *testing.B) {
+	const (
+		segSize   = 1000
+		batchSize = 100
+	)
+
+	ctx := context.Background()
+	l, err := New(ctx, WithMaxSegmentSize(segSize))
+	if err != nil {
+		b.Fatalf("create log: %v", err)
+	}
+
+	batch := make([][]byte, batchSize)
+	for i := range batch {
+		batch[i] = []byte(`{"id":"1","message":"benchmark"}`)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = l.WriteBatch(ctx, batch); err != nil {
+			b.Fatalf("write batch: %v", err)
+		}
+	}
+}
+
+func BenchmarkLog_write_timestamps(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "with timestamps"},
+		{name: "without timestamps", opts: []Option{WithoutTimestamps()}},
+	}
+
+	d := []byte(`{"id":"1","message":"benchmark"}`)
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			ctx := context.Background()
+			l, err := New(ctx, append(bm.opts, WithMaxSegmentSize(1000))...)
+			if err != nil {
+				b.Fatalf("create log: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err = l.write(ctx, d); err != nil {
+					b.Fatalf("write data: %v", err)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkLog_read(b *testing.B) {
 	const (
 		start   = Offset(0)
@@ -83,3 +138,202 @@ func BenchmarkLog_read(b *testing.B) {
 
 	_ = result
 }
+
+func BenchmarkPartitionedLog_Write(b *testing.B) {
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(strconv.Itoa(n)+"_partitions", func(b *testing.B) {
+			ctx := context.Background()
+			l, err := New(ctx, WithMaxSegmentSize(1000))
+			if err != nil {
+				b.Fatalf("create log: %v", err)
+			}
+
+			pl, err := l.Partition(n)
+			if err != nil {
+				b.Fatalf("partition log: %v", err)
+			}
+
+			d := []byte(`{"id":"1","message":"benchmark"}`)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := pl.Write(ctx, d); err != nil {
+						b.Errorf("write data: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkLog_write_purgeBatch(b *testing.B) {
+	for _, n := range []int{1, 10, 100} {
+		b.Run(strconv.Itoa(n)+"_segments", func(b *testing.B) {
+			ctx := context.Background()
+			l, err := New(ctx, WithMaxSegmentSize(10), WithPurgeBatch(n))
+			if err != nil {
+				b.Fatalf("create log: %v", err)
+			}
+
+			d := []byte(`{"id":"1","message":"benchmark"}`)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err = l.write(ctx, d); err != nil {
+					b.Fatalf("write data: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkProcess simulates non-trivial per record processing
+func benchmarkProcess(r Record) byte {
+	var sum byte
+	for i := 0; i < 1000; i++ {
+		sum += r.Data[i%len(r.Data)]
+	}
+	return sum
+}
+
+func BenchmarkLog_Stream(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l, err := New(ctx, WithMaxSegmentSize(b.N+1))
+	if err != nil {
+		b.Fatalf("create log: %v", err)
+	}
+
+	d := []byte(`{"id":"1","message":"benchmark"}`)
+	for i := 0; i < b.N; i++ {
+		if _, err = l.Write(ctx, d); err != nil {
+			b.Fatalf("write data: %v", err)
+		}
+	}
+
+	var result byte
+	s := l.Stream(ctx, 0)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, ok := s.Next()
+		if !ok {
+			b.Fatalf("stream records: %v", s.Err())
+		}
+		result += benchmarkProcess(r)
+	}
+
+	_ = result
+}
+
+func BenchmarkLog_NewPrefetchReader(b *testing.B) {
+	ctx := context.Background()
+	l, err := New(ctx, WithMaxSegmentSize(b.N+1))
+	if err != nil {
+		b.Fatalf("create log: %v", err)
+	}
+
+	d := []byte(`{"id":"1","message":"benchmark"}`)
+	for i := 0; i < b.N; i++ {
+		if _, err = l.Write(ctx, d); err != nil {
+			b.Fatalf("write data: %v", err)
+		}
+	}
+
+	var result byte
+	p := l.NewPrefetchReader(ctx, 0, 128)
+	defer p.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := p.Next()
+		if err != nil {
+			b.Fatalf("read records: %v", err)
+		}
+		result += benchmarkProcess(r)
+	}
+
+	_ = result
+}
+
+func BenchmarkLog_TrimMemory(b *testing.B) {
+	const segSize = 100_000
+
+	ctx := context.Background()
+	d := []byte(`{"id":"1","message":"benchmark"}`)
+
+	var reclaimed uint64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		l, err := New(ctx, WithMaxSegmentSize(segSize))
+		if err != nil {
+			b.Fatalf("create log: %v", err)
+		}
+
+		if _, err = l.write(ctx, d); err != nil {
+			b.Fatalf("write data: %v", err)
+		}
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.StartTimer()
+
+		if err = l.TrimMemory(ctx); err != nil {
+			b.Fatalf("trim memory: %v", err)
+		}
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		if before.HeapInuse > after.HeapInuse {
+			reclaimed += before.HeapInuse - after.HeapInuse
+		}
+		runtime.KeepAlive(l)
+		b.StartTimer()
+	}
+
+	b.ReportMetric(float64(reclaimed)/float64(b.N), "reclaimed-B/op")
+}
+
+func BenchmarkLog_getSegment(b *testing.B) {
+	const (
+		segSize    = 10
+		purgeBatch = 1000
+	)
+
+	ctx := context.Background()
+	l, err := New(ctx, WithMaxSegmentSize(segSize), WithPurgeBatch(purgeBatch))
+	if err != nil {
+		b.Fatalf("create log: %v", err)
+	}
+
+	d := []byte(`{"id":"1","message":"benchmark"}`)
+	for i := 0; i < segSize*purgeBatch; i++ {
+		if _, err = l.write(ctx, d); err != nil {
+			b.Fatalf("write data: %v", err)
+		}
+	}
+
+	// newest history segment
+	offset := Offset(segSize*purgeBatch - segSize - 1)
+
+	for _, linear := range []bool{true, false} {
+		name := "binary"
+		if linear {
+			name = "linear"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			l.conf.linearSearch = linear
+			for i := 0; i < b.N; i++ {
+				if _, err := l.getSegment(offset); err != nil {
+					b.Fatalf("get segment: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"math"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/clock"
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 )
 
 func TestRecord_immutable(t *testing.T) {
@@ -32,6 +34,21 @@ func TestRecord_immutable(t *testing.T) {
 		}
 	})
 
+	t.Run("WithData", func(t *testing.T) {
+		now := time.Now().UTC()
+		r := Record{Metadata: Header{Offset: 1, Created: now}, Data: newTestData(t, "1")}
+
+		data := newTestData(t, "2")
+		got := r.WithData(data)
+		assert.DeepEqual(t, got.Metadata, r.Metadata)
+		assert.DeepEqual(t, got.Data, data)
+
+		// modify source data
+		data[0] = 'x'
+		assert.DeepEqual(t, got.Data, newTestData(t, "2"))
+		assert.DeepEqual(t, r.Data, newTestData(t, "1"))
+	})
+
 	t.Run("write, read, modify record, read", func(t *testing.T) {
 		ctx := context.Background()
 		c := clock.NewMock()
@@ -81,6 +98,30 @@ func Test_New(t *testing.T) {
 			{"invalid start offset", WithStartOffset(-1), "must not be negative"},
 			{"invalid segment size", WithMaxSegmentSize(-4), "must be greater than 0"},
 			{"invalid record size", WithMaxRecordDataSize(0), "must be greater than 0"},
+			{"invalid prealloc size", WithMaxPreallocBytes(0), "must be greater than 0"},
+			{"fault injector is nil", WithFaultInjector(nil), "must not be nil"},
+			{"write interceptor is nil", WithWriteInterceptor(nil), "must not be nil"},
+			{"invalid read batch size", WithMaxReadBatch(0), "must be greater than 0"},
+			{"invalid read yield", WithReadYield(0), "must be greater than 0"},
+			{"invalid extend retries", WithExtendFailurePolicy(ExtendFailureRetry(-1)), "must not be negative"},
+			{"invalid rate window", WithRateTracker(0), "must be at least 10ns"},
+			{"dedupe key func is nil", WithDedupeKeyFunc(nil), "must not be nil"},
+			{"invalid purge batch", WithPurgeBatch(0), "must be greater than 0"},
+			{"invalid time index granularity", WithTimeIndex(0), "must be greater than 0"},
+			{"invalid stream rate limit", WithStreamRateLimit(-1), "must not be negative"},
+			{"invalid dead letter capacity", WithDeadLetter(0), "must be greater than 0"},
+			{"invalid default timeout", WithDefaultTimeout(0), "must be greater than 0"},
+			{"first write hook is nil", WithFirstWriteHook(nil), "must not be nil"},
+			{"user value key is nil", WithUserValue(nil, "value"), "must not be nil"},
+			{"segment store function is nil", WithSegmentStore(nil), "must not be nil"},
+			{"persistence directory is empty", WithPersistence(""), "must not be empty"},
+			{"invalid retention", WithRetention(0), "must be greater than 0"},
+			{"invalid history segments", WithMaxHistorySegments(0), "must be greater than 0"},
+			{"invalid dedupe window", WithDedupeWindow(0), "must be greater than 0"},
+			{"invalid encryption key", WithEncryption("key-1", []byte("key")), "invalid encryption key"},
+			{"read interceptor is nil", WithReadInterceptor(nil), "must not be nil"},
+			{"purge handler is nil", WithPurgeHandler(nil), "must not be nil"},
+			{"user value key not comparable", WithUserValue([]byte("key"), "value"), "must be comparable"},
 		}
 
 		for _, tc := range testCases {
@@ -93,6 +134,61 @@ func Test_New(t *testing.T) {
 		}
 	})
 
+	t.Run("fails with combined error for multiple invalid options", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx, WithClock(nil), WithMaxSegmentSize(0))
+		assert.ErrorContains(t, err, "clock must not be nil; size must be greater than 0")
+		assert.Assert(t, l == nil)
+	})
+
+	t.Run("fails when start offset overflows", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx, WithStartOffset(math.MaxInt-10), WithMaxSegmentSize(10))
+		assert.ErrorContains(t, err, "start offset too large for segment size")
+		assert.Assert(t, l == nil)
+	})
+
+	t.Run("fails when segment preallocation exceeds maximum", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx, WithMaxSegmentSize(1024), WithMaxPreallocBytes(1024))
+		assert.ErrorContains(t, err, "exceeds maximum of 1024 bytes")
+		assert.Assert(t, l == nil)
+	})
+
+	t.Run("fails when options are combined invalidly", func(t *testing.T) {
+		keyFn := func(data []byte) []byte { return data }
+		newStore := func(size int) SegmentStore { return newSliceStore(size) }
+		key := make([]byte, 32)
+
+		testCases := []struct {
+			name  string
+			opts  []Option
+			error string
+		}{
+			{"time index without timestamps", []Option{WithoutTimestamps(), WithTimeIndex(time.Second)}, "time index, rate tracker, retention and dedupe window require timestamps"},
+			{"rate tracker without timestamps", []Option{WithoutTimestamps(), WithRateTracker(time.Second)}, "time index, rate tracker, retention and dedupe window require timestamps"},
+			{"retention without timestamps", []Option{WithoutTimestamps(), WithRetention(time.Second)}, "time index, rate tracker, retention and dedupe window require timestamps"},
+			{"dedupe window without timestamps", []Option{WithoutTimestamps(), WithDedupeWindow(time.Second)}, "time index, rate tracker, retention and dedupe window require timestamps"},
+			{"chunking with deduplication", []Option{WithChunking(), WithDedupeKeyFunc(keyFn)}, "chunking is not supported with deduplication"},
+			{"encryption with deduplication", []Option{WithEncryption("key-1", key), WithDedupeKeyFunc(keyFn)}, "encryption is not supported with deduplication"},
+			{"compaction with custom segment store", []Option{WithCompaction(), WithSegmentStore(newStore)}, "compaction requires the default segment store"},
+			{
+				"multiple invalid combinations",
+				[]Option{WithChunking(), WithEncryption("key-1", key), WithDedupeKeyFunc(keyFn)},
+				"chunking is not supported with deduplication; encryption is not supported with deduplication",
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				ctx := context.Background()
+				l, err := New(ctx, tc.opts...)
+				assert.ErrorContains(t, err, "validate log configuration: "+tc.error)
+				assert.Assert(t, l == nil)
+			})
+		}
+	})
+
 	t.Run("creates log with defaults", func(t *testing.T) {
 		ctx := context.Background()
 		l, err := New(ctx)
@@ -103,13 +199,14 @@ func Test_New(t *testing.T) {
 		assert.Equal(t, l.conf.startOffset, DefaultStartOffset)
 		assert.Equal(t, l.conf.segmentSize, DefaultSegmentSize)
 		assert.Equal(t, l.conf.maxRecordSize, DefaultMaxRecordDataBytes)
+		assert.Equal(t, l.conf.maxPreallocSize, DefaultMaxPreallocBytes)
 
 		// 	fields
 		assert.Assert(t, l.clock != nil)
 		assert.Assert(t, l.active != nil)
 		assert.Equal(t, l.active.start, DefaultStartOffset)
 		assert.Equal(t, l.active.currentOffset(), Offset(-1))
-		assert.DeepEqual(t, l.history, (*segment)(nil))
+		assert.Equal(t, len(l.history), 0)
 	})
 }
 
@@ -205,12 +302,13 @@ func TestLog_write(t *testing.T) {
 
 				// assert no history/purge
 				if len(tc.records) < tc.segSize {
-					assert.DeepEqual(t, l.history, (*segment)(nil))
+					assert.Equal(t, len(l.history), 0)
 				}
 
 				if len(tc.records) > tc.segSize {
-					assert.Equal(t, len(l.active.data), len(tc.records)-tc.segSize)
-					assert.Equal(t, len(l.history.data), tc.segSize)
+					assert.Equal(t, l.active.len(), len(tc.records)-tc.segSize)
+					assert.Equal(t, len(l.history), 1)
+					assert.Equal(t, l.history[0].len(), tc.segSize)
 				}
 			})
 		}
@@ -354,8 +452,9 @@ func TestLog_read(t *testing.T) {
 					got, writeErr := l.read(ctx, offset)
 					expected := Record{
 						Metadata: Header{
-							Offset:  Offset(i) + tc.start,
-							Created: now,
+							Offset:   Offset(i) + tc.start,
+							Created:  now,
+							Checksum: checksum(tc.records[i]),
 						},
 						Data: tc.records[i],
 					}
@@ -368,6 +467,317 @@ func TestLog_read(t *testing.T) {
 	})
 }
 
+func TestLog_readBatch_yield(t *testing.T) {
+	t.Run("reads all records while yielding", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx, WithMaxSegmentSize(10), WithReadYield(3))
+		assert.NilError(t, err)
+
+		for _, d := range NewTestDataSlice(t, 15) {
+			_, err = l.write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		records := make([]Record, 15)
+		count, err := l.ReadBatch(ctx, 0, records)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 15)
+
+		gap, ok, err := l.VerifyContiguous(ctx, 0, 14)
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+		assert.Equal(t, gap, Offset(-1))
+	})
+
+	t.Run("returns partial batch when purged while yielding", func(t *testing.T) {
+		ctx := context.Background()
+
+		var (
+			l       *Log
+			written = make(chan struct{})
+		)
+
+		// start a purging writer while holding the read lock before the yield
+		fault := func(op string, offset Offset) error {
+			if op == FaultOpRead && offset == 4 {
+				go func() {
+					defer close(written)
+					_, err := l.Write(ctx, []byte("purge"))
+					assert.Check(t, err)
+				}()
+				time.Sleep(100 * time.Millisecond) // writer blocks on lock
+			}
+			return nil
+		}
+
+		var err error
+		l, err = New(ctx, WithMaxSegmentSize(10), WithReadYield(5), WithFaultInjector(fault))
+		assert.NilError(t, err)
+
+		// history [0-9], active [10-19], next write purges history
+		for _, d := range NewTestDataSlice(t, 20) {
+			_, err = l.write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		records := make([]Record, 10)
+		count, err := l.ReadBatch(ctx, 0, records)
+		assert.Assert(t, errors.Is(err, ErrOutOfRange))
+		assert.Equal(t, count, 5)
+		<-written
+	})
+}
+
+func TestLog_extendFailurePolicy(t *testing.T) {
+	testCases := []struct {
+		name      string
+		policy    ExtendFailurePolicy
+		wantPanic bool
+	}{
+		{name: "panics on extend failure", policy: ExtendFailurePanic, wantPanic: true},
+		{name: "returns error on extend failure", policy: ExtendFailureError},
+		{name: "returns error after retries", policy: ExtendFailureRetry(3)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			l, err := New(ctx, WithMaxSegmentSize(5), WithExtendFailurePolicy(tc.policy))
+			assert.NilError(t, err)
+
+			for _, d := range NewTestDataSlice(t, 5) {
+				_, err = l.write(ctx, d)
+				assert.NilError(t, err)
+			}
+
+			// force newSegment to fail
+			l.conf.segmentSize = 0
+
+			if tc.wantPanic {
+				assert.Assert(t, cmp.Panics(func() {
+					_, _ = l.write(ctx, []byte("data"))
+				}))
+				return
+			}
+
+			offset, err := l.write(ctx, []byte("data"))
+			assert.Assert(t, errors.Is(err, ErrExtendFailed))
+			assert.Equal(t, offset, Offset(-1))
+
+			// recovers from sealed active segment
+			l.conf.segmentSize = 5
+			offset, err = l.write(ctx, []byte("data"))
+			assert.NilError(t, err)
+			assert.Equal(t, offset, Offset(5))
+
+			r, err := l.read(ctx, 0)
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Offset, Offset(0))
+		})
+	}
+}
+
+func TestLog_purgeBatch(t *testing.T) {
+	ctx := context.Background()
+	l, err := New(ctx, WithMaxSegmentSize(2), WithPurgeBatch(3))
+	assert.NilError(t, err)
+
+	testCases := []struct {
+		records      int // total records written
+		wantHistory  int
+		wantEarliest Offset
+	}{
+		{records: 2, wantHistory: 0, wantEarliest: 0},
+		{records: 4, wantHistory: 1, wantEarliest: 0},
+		{records: 8, wantHistory: 3, wantEarliest: 0},
+		{records: 9, wantHistory: 1, wantEarliest: 6}, // purges 3 segments at once
+		{records: 14, wantHistory: 3, wantEarliest: 6},
+		{records: 15, wantHistory: 1, wantEarliest: 12},
+	}
+
+	written := 0
+	for _, tc := range testCases {
+		for ; written < tc.records; written++ {
+			_, err = l.write(ctx, []byte("data"))
+			assert.NilError(t, err)
+		}
+
+		assert.Equal(t, len(l.history), tc.wantHistory, "records: %d", tc.records)
+		earliest, _ := l.offsetRange()
+		assert.Equal(t, earliest, tc.wantEarliest, "records: %d", tc.records)
+
+		// all retained records are readable
+		for offset := earliest; offset < l.offset; offset++ {
+			r, err := l.read(ctx, offset)
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Offset, offset)
+		}
+	}
+}
+
+func TestLog_getSegment_search(t *testing.T) {
+	ctx := context.Background()
+	l, err := New(ctx, WithStartOffset(5), WithMaxSegmentSize(3), WithPurgeBatch(10))
+	assert.NilError(t, err)
+
+	// purges offsets [5-34]
+	for i := 0; i < 50; i++ {
+		_, err = l.write(ctx, []byte("data"))
+		assert.NilError(t, err)
+	}
+
+	for offset := Offset(0); offset < 60; offset++ {
+		l.conf.linearSearch = false
+		binary, binaryErr := l.getSegment(offset)
+
+		l.conf.linearSearch = true
+		linear, linearErr := l.getSegment(offset)
+
+		assert.Equal(t, binary, linear, "offset %d", offset)
+		assert.Equal(t, binaryErr, linearErr, "offset %d", offset)
+	}
+}
+
+func TestLog_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		corrupt func(l *Log)
+		wantErr string
+	}{
+		{name: "valid log", corrupt: func(l *Log) {}},
+		{
+			name:    "active segment sealed",
+			corrupt: func(l *Log) { l.active.seal() },
+			wantErr: "active segment at offset 20 is sealed",
+		},
+		{
+			name:    "history segment not sealed",
+			corrupt: func(l *Log) { l.history[0].sealed = false },
+			wantErr: "history segment at offset 10 is not sealed",
+		},
+		{
+			name: "history segment not full",
+			corrupt: func(l *Log) {
+				store := l.history[0].store.(*sliceStore)
+				store.records = store.records[:9]
+			},
+			wantErr: "history segment at offset 10 is not full",
+		},
+		{
+			name:    "segment size mismatch",
+			corrupt: func(l *Log) { l.active.size = 5 },
+			wantErr: "segment at offset 20 has size 5, want 10",
+		},
+		{
+			name:    "non-contiguous segments",
+			corrupt: func(l *Log) { l.active.start = 21 },
+			wantErr: "segment at offset 21 does not follow segment at offset 10",
+		},
+		{
+			name:    "non-contiguous records",
+			corrupt: func(l *Log) { l.history[0].store.(*sliceStore).records[3].Metadata.Offset = 99 },
+			wantErr: "contains record with offset 99, want 13",
+		},
+		{
+			name:    "record too large",
+			corrupt: func(l *Log) { l.active.store.(*sliceStore).records[0].Data = make([]byte, 11) },
+			wantErr: "record at offset 20: record data too large",
+		},
+		{
+			name:    "record without data",
+			corrupt: func(l *Log) { l.active.store.(*sliceStore).records[0].Data = nil },
+			wantErr: "record at offset 20 has no data",
+		},
+		{
+			name:    "next write offset mismatch",
+			corrupt: func(l *Log) { l.offset = 30 },
+			wantErr: "next write offset is 30, want 25",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			l, err := New(ctx, WithMaxSegmentSize(10), WithMaxRecordDataSize(10))
+			assert.NilError(t, err)
+
+			// purges offsets [0-9]
+			for i := 0; i < 25; i++ {
+				_, err = l.write(ctx, []byte("data"))
+				assert.NilError(t, err)
+			}
+
+			tc.corrupt(l)
+			err = l.Validate()
+			if tc.wantErr == "" {
+				assert.NilError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestLog_notify(t *testing.T) {
+	ctx := context.Background()
+	l, err := New(ctx)
+	assert.NilError(t, err)
+
+	changed := l.subscribe()
+	select {
+	case <-changed:
+		t.Fatal("channel must not be closed before modification")
+	default:
+	}
+
+	_, err = l.Write(ctx, []byte("data"))
+	assert.NilError(t, err)
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("channel must be closed after modification")
+	}
+}
+
+func TestLog_timeIndex(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewMock()
+	l, err := New(ctx, WithClock(c), WithMaxSegmentSize(6), WithTimeIndex(time.Minute))
+	assert.NilError(t, err)
+
+	// 4 records per bucket
+	for i := 0; i < 12; i++ {
+		_, err = l.write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		c.Add(15 * time.Second)
+	}
+
+	assert.DeepEqual(t, l.timeIdx.buckets, map[int64]Offset{
+		0: 0,
+		1: 4,
+		2: 8,
+	})
+
+	// purges offsets [0-5], bucket of offset 6 points to earliest offset
+	_, err = l.write(ctx, []byte("data"))
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, l.timeIdx.buckets, map[int64]Offset{
+		1: 6,
+		2: 8,
+		3: 12,
+	})
+}
+
+func Test_timeIndex_bucket(t *testing.T) {
+	ti := newTimeIndex(time.Second)
+	assert.Equal(t, ti.bucket(time.Unix(1, 0)), int64(1))
+	assert.Equal(t, ti.bucket(time.Unix(1, 999)), int64(1))
+	assert.Equal(t, ti.bucket(time.Unix(0, -1)), int64(-1))
+	assert.Equal(t, ti.bucket(time.Unix(-1, 0)), int64(-1))
+}
+
 func Test_offsetRange(t *testing.T) {
 	type wantOffsets struct {
 		earliest Offset
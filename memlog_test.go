@@ -1,10 +1,17 @@
 package memlog_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/benbjohnson/clock"
 	"golang.org/x/sync/errgroup"
@@ -178,8 +185,9 @@ func TestLog_ReadBatch(t *testing.T) {
 
 					wroteRecords[i] = memlog.Record{
 						Metadata: memlog.Header{
-							Offset:  offset,
-							Created: mockClock.Now(),
+							Offset:   offset,
+							Created:  mockClock.Now(),
+							Checksum: crc32.Checksum(d, crc32.MakeTable(crc32.Castagnoli)),
 						},
 						Data: d,
 					}
@@ -290,6 +298,108 @@ func TestLog_ReadBatch(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("reads at most max read batch records", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxReadBatch(3))
+		assert.NilError(t, err)
+
+		for _, d := range memlog.NewTestDataSlice(t, 10) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		records := make([]memlog.Record, 10)
+		count, err := l.ReadBatch(ctx, 0, records)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 3)
+
+		count, err = l.ReadBatch(ctx, 9, records)
+		assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+		assert.Equal(t, count, 1)
+	})
+
+	t.Run("returns partial batch when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// cancel when reading offset 500
+		cancelAt := func(op string, offset memlog.Offset) error {
+			if op == memlog.FaultOpRead && offset == 500 {
+				cancel()
+			}
+			return nil
+		}
+
+		l, err := memlog.New(ctx, memlog.WithFaultInjector(cancelAt))
+		assert.NilError(t, err)
+
+		for _, d := range memlog.NewTestDataSlice(t, 1000) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		records := make([]memlog.Record, 1000)
+		count, err := l.ReadBatch(ctx, 0, records)
+		assert.Assert(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, count, 500)
+
+		count, err = l.ReadBatch(ctx, 0, records)
+		assert.Assert(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, count, 0)
+	})
+}
+
+func TestLog_EOFSemantics(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithEOFSemantics())
+	assert.NilError(t, err)
+
+	_, err = l.Read(ctx, 0)
+	assert.Equal(t, err, io.EOF)
+
+	data := memlog.NewTestDataSlice(t, 10)
+	for _, d := range data {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	var (
+		offset  memlog.Offset
+		records []memlog.Record
+	)
+
+	batch := make([]memlog.Record, 3)
+	for {
+		count, err := l.ReadBatch(ctx, offset, batch)
+		records = append(records, batch[:count]...)
+		offset += memlog.Offset(count)
+
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, len(records), len(data))
+
+	// stream handles end of log
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := l.Stream(sctx, 9)
+	r, ok := s.Next()
+	assert.Assert(t, ok)
+	assert.Equal(t, r.Metadata.Offset, memlog.Offset(9))
+
+	// Next blocks until the write
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = l.Write(ctx, []byte("data"))
+	}()
+
+	r, ok = s.Next()
+	assert.Assert(t, ok)
+	assert.Equal(t, r.Metadata.Offset, memlog.Offset(10))
 }
 
 func TestLog_Checkpoint_Resume(t *testing.T) {
@@ -506,3 +616,2341 @@ func dedupe(t *testing.T, records []memlog.Record) []memlog.Record {
 
 	return deduped
 }
+
+func TestLog_FaultInjector(t *testing.T) {
+	errInjected := errors.New("injected")
+
+	ctx := context.Background()
+	fault := func(op string, offset memlog.Offset) error {
+		switch {
+		case op == memlog.FaultOpWrite && offset == 2:
+			return errInjected
+		case op == memlog.FaultOpRead && offset == 1:
+			return memlog.ErrOutOfRange
+		}
+		return nil
+	}
+
+	l, err := memlog.New(ctx, memlog.WithFaultInjector(fault))
+	assert.NilError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+	}
+
+	offset, err := l.Write(ctx, []byte("data"))
+	assert.Assert(t, errors.Is(err, errInjected))
+	assert.Equal(t, offset, memlog.Offset(-1))
+
+	_, err = l.Read(ctx, 0)
+	assert.NilError(t, err)
+
+	_, err = l.Read(ctx, 1)
+	assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+
+	records := make([]memlog.Record, 2)
+	count, err := l.ReadBatch(ctx, 0, records)
+	assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+	assert.Equal(t, count, 0)
+}
+
+func TestLog_OldestTime(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewMock()
+	start := c.Now().UTC()
+
+	l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	_, ok := l.OldestTime(ctx)
+	assert.Assert(t, !ok)
+
+	for _, d := range memlog.NewTestDataSlice(t, 20) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+		c.Add(time.Second)
+	}
+
+	oldest, ok := l.OldestTime(ctx)
+	assert.Assert(t, ok)
+	assert.Equal(t, oldest, start)
+
+	// purges offsets [0-9]
+	_, err = l.Write(ctx, []byte("data"))
+	assert.NilError(t, err)
+
+	oldest, ok = l.OldestTime(ctx)
+	assert.Assert(t, ok)
+	assert.Equal(t, oldest, start.Add(10*time.Second))
+}
+
+func TestLog_LastWriteTime(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewMock()
+	start := c.Now().UTC()
+
+	l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	_, ok := l.LastWriteTime(ctx)
+	assert.Assert(t, !ok)
+
+	// purges offsets [0-9]
+	for _, d := range memlog.NewTestDataSlice(t, 21) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+		c.Add(time.Second)
+	}
+
+	last, ok := l.LastWriteTime(ctx)
+	assert.Assert(t, ok)
+	assert.Equal(t, last, start.Add(20*time.Second))
+}
+
+func TestLog_Value(t *testing.T) {
+	type key string
+
+	ctx := context.Background()
+	l, err := memlog.New(ctx,
+		memlog.WithUserValue(key("service"), "orders"),
+		memlog.WithUserValue(key("owner"), "team-a"),
+		memlog.WithUserValue(key("owner"), "team-b"),
+	)
+	assert.NilError(t, err)
+
+	assert.Equal(t, l.Value(key("service")), "orders")
+	assert.Equal(t, l.Value(key("owner")), "team-b")
+	assert.Equal(t, l.Value(key("unknown")), nil)
+
+	// different key type
+	assert.Equal(t, l.Value("service"), nil)
+}
+
+func TestLog_WithoutTimestamps(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithoutTimestamps(), memlog.WithClock(clock.NewMock()))
+	assert.NilError(t, err)
+
+	offset, err := l.Write(ctx, []byte("data"))
+	assert.NilError(t, err)
+	assert.Equal(t, offset, memlog.Offset(0))
+
+	// record at offset 0 without creation time must not be treated as empty
+	r, err := l.Read(ctx, offset)
+	assert.NilError(t, err)
+	assert.Equal(t, r.Metadata.Offset, memlog.Offset(0))
+	assert.Assert(t, r.Metadata.Created.IsZero())
+	assert.DeepEqual(t, r.Data, []byte("data"))
+
+	_, err = l.OffsetForTime(ctx, time.Unix(0, 0))
+	assert.ErrorContains(t, err, "without timestamps")
+
+	_, err = l.Age(ctx, offset)
+	assert.ErrorContains(t, err, "without timestamps")
+}
+
+func TestLog_OffsetForTime_EqualTimestamps(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []memlog.Option
+	}{
+		{name: "binary search", opts: nil},
+		{name: "time index", opts: []memlog.Option{memlog.WithTimeIndex(time.Second)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := clock.NewMock()
+			t0 := c.Now()
+
+			opts := append([]memlog.Option{memlog.WithClock(c), memlog.WithMaxSegmentSize(100)}, tc.opts...)
+			l, err := memlog.New(ctx, opts...)
+			assert.NilError(t, err)
+
+			// frozen clock, 150 records per timestamp
+			for _, d := range memlog.NewTestDataSlice(t, 150) {
+				_, err = l.Write(ctx, d)
+				assert.NilError(t, err)
+			}
+
+			c.Add(time.Nanosecond)
+			t1 := c.Now()
+			for _, d := range memlog.NewTestDataSlice(t, 150) {
+				_, err = l.Write(ctx, d)
+				assert.NilError(t, err)
+			}
+
+			// offsets [0-99] purged
+			offset, err := l.OffsetForTime(ctx, t0)
+			assert.NilError(t, err)
+			assert.Equal(t, offset, memlog.Offset(100))
+
+			offset, err = l.OffsetForTime(ctx, t1)
+			assert.NilError(t, err)
+			assert.Equal(t, offset, memlog.Offset(150))
+
+			_, err = l.OffsetForTime(ctx, t1.Add(time.Nanosecond))
+			assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+		})
+	}
+}
+
+func TestLog_Stats(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewMock()
+	l, err := memlog.New(ctx, memlog.WithName("orders"), memlog.WithMaxSegmentSize(10), memlog.WithClock(c))
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, l.Stats(ctx), memlog.Stats{
+		Name:            "orders",
+		SegmentsCreated: 1,
+		Earliest:        memlog.InvalidOffset,
+		Latest:          memlog.InvalidOffset,
+	})
+
+	// purges offsets [0-9]
+	for i := 0; i < 21; i++ {
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+	}
+
+	for _, offset := range []memlog.Offset{10, 20} {
+		_, err = l.Read(ctx, offset)
+		assert.NilError(t, err)
+	}
+
+	// failed reads are not counted
+	_, err = l.Read(ctx, 0)
+	assert.ErrorIs(t, err, memlog.ErrOutOfRange)
+
+	assert.DeepEqual(t, l.Stats(ctx), memlog.Stats{
+		Name:            "orders",
+		Records:         11,
+		Bytes:           44,
+		Purges:          1,
+		SegmentsCreated: 3,
+		SegmentsPurged:  1,
+		Writes:          21,
+		Reads:           2,
+		Earliest:        10,
+		Latest:          20,
+		ActiveFillRatio: 0.1,
+		LastWrite:       c.Now().UTC(),
+	})
+
+	// unwritten reserved offsets are not counted
+	_, err = l.Reserve(ctx, 2)
+	assert.NilError(t, err)
+
+	stats := l.Stats(ctx)
+	assert.Equal(t, stats.Records, 11)
+	assert.Equal(t, stats.ActiveFillRatio, 0.1)
+	assert.Equal(t, stats.ActiveFillRatio, l.ActiveFillRatio(ctx))
+	assert.Equal(t, stats.Latest, memlog.Offset(22))
+}
+
+func TestLog_LenSizeBytes(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	assert.Equal(t, l.Len(), 0)
+	assert.Equal(t, l.SizeBytes(), 0)
+
+	_, err = l.Write(ctx, []byte("data"))
+	assert.NilError(t, err)
+
+	assert.Equal(t, l.Len(), 1)
+	size := l.SizeBytes()
+	assert.Assert(t, size > 4, "size must include record overhead")
+
+	// purges offsets [0-9]
+	for i := 0; i < 20; i++ {
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+	}
+
+	assert.Equal(t, l.Len(), 11)
+	assert.Equal(t, l.SizeBytes(), 11*size)
+
+	t.Run("truncated records are retained in memory", func(t *testing.T) {
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		for i := 0; i < 15; i++ {
+			_, err = l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+		}
+
+		// releases segment [0-9], offsets [10-11] retained in memory
+		assert.NilError(t, l.Truncate(ctx, 12))
+		assert.Equal(t, l.Len(), 3)
+		assert.Equal(t, l.SizeBytes(), 5*size)
+		assert.Equal(t, l.Stats(ctx).Records, 3)
+
+		// purges segment [10-19]
+		for i := 0; i < 16; i++ {
+			_, err = l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+		}
+		assert.Equal(t, l.Len(), 11)
+		assert.Equal(t, l.SizeBytes(), 11*size)
+	})
+
+	t.Run("compacted records and unwritten reserved offsets are not counted", func(t *testing.T) {
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2), memlog.WithCompaction())
+		assert.NilError(t, err)
+
+		for _, key := range []string{"a", "a", "b"} {
+			_, err = l.WriteKey(ctx, []byte(key), []byte("data"))
+			assert.NilError(t, err)
+		}
+		assert.NilError(t, l.Compact(ctx))
+
+		start, err := l.Reserve(ctx, 1)
+		assert.NilError(t, err)
+
+		// offset 0 compacted, offset 3 reserved
+		assert.Equal(t, l.Len(), 2)
+		assert.Equal(t, l.SizeBytes(), 2*size)
+
+		assert.NilError(t, l.WriteReserved(ctx, start, []byte("data")))
+		assert.Equal(t, l.Len(), 3)
+		assert.Equal(t, l.SizeBytes(), 3*size)
+	})
+}
+
+func TestLog_TrimMemory(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	data := memlog.NewTestDataSlice(t, 15)
+	for _, d := range data[:3] {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	assert.NilError(t, l.TrimMemory(ctx))
+	assert.Equal(t, l.ActiveFillRatio(ctx), 0.3)
+
+	// trimmed segment grows until full
+	for _, d := range data[3:] {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	for i, d := range data {
+		r, err := l.Read(ctx, memlog.Offset(i))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, d)
+	}
+	assert.Equal(t, l.ActiveFillRatio(ctx), 0.5)
+}
+
+func TestLog_FirstWriteHook(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		calls int32
+		first memlog.Offset
+	)
+
+	hook := func(offset memlog.Offset) {
+		atomic.AddInt32(&calls, 1)
+		first = offset
+	}
+
+	l, err := memlog.New(ctx, memlog.WithStartOffset(10), memlog.WithFirstWriteHook(hook), memlog.WithMaxRecordDataSize(10))
+	assert.NilError(t, err)
+
+	// failed writes do not fire the hook
+	_, err = l.Write(ctx, []byte("too large data"))
+	assert.Assert(t, errors.Is(err, memlog.ErrRecordTooLarge))
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(0))
+
+	var eg errgroup.Group
+	for i := 0; i < 10; i++ {
+		eg.Go(func() error {
+			_, err := l.Write(ctx, []byte("data"))
+			return err
+		})
+	}
+	assert.NilError(t, eg.Wait())
+
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+	assert.Equal(t, first, memlog.Offset(10))
+}
+
+func TestLog_Name(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, l.Name(), "")
+
+	l, err = memlog.New(ctx, memlog.WithName("orders"))
+	assert.NilError(t, err)
+	assert.Equal(t, l.Name(), "orders")
+}
+
+func TestLog_Segments(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithStartOffset(10), memlog.WithMaxSegmentSize(5), memlog.WithPurgeBatch(2))
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, l.Segments(ctx), []memlog.SegmentInfo{
+		{Start: 10, End: memlog.InvalidOffset, Sealed: false},
+	})
+
+	// purges offsets [10-19]
+	for _, d := range memlog.NewTestDataSlice(t, 23) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	assert.DeepEqual(t, l.Segments(ctx), []memlog.SegmentInfo{
+		{Start: 20, End: 24, Sealed: true},
+		{Start: 25, End: 29, Sealed: true},
+		{Start: 30, End: 32, Sealed: false},
+	})
+}
+
+func TestLog_WithMaxHistorySegments(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []memlog.Option
+		want []memlog.SegmentInfo
+	}{
+		{
+			name: "purges oldest history segment",
+			opts: []memlog.Option{memlog.WithMaxHistorySegments(3)},
+			want: []memlog.SegmentInfo{
+				{Start: 15, End: 19, Sealed: true},
+				{Start: 20, End: 24, Sealed: true},
+				{Start: 25, End: 29, Sealed: true},
+				{Start: 30, End: 32, Sealed: false},
+			},
+		},
+		{
+			name: "purges batch of history segments",
+			opts: []memlog.Option{memlog.WithMaxHistorySegments(3), memlog.WithPurgeBatch(2)},
+			want: []memlog.SegmentInfo{
+				{Start: 20, End: 24, Sealed: true},
+				{Start: 25, End: 29, Sealed: true},
+				{Start: 30, End: 32, Sealed: false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			opts := append([]memlog.Option{memlog.WithStartOffset(10), memlog.WithMaxSegmentSize(5)}, tc.opts...)
+			l, err := memlog.New(ctx, opts...)
+			assert.NilError(t, err)
+
+			for _, d := range memlog.NewTestDataSlice(t, 23) {
+				_, err = l.Write(ctx, d)
+				assert.NilError(t, err)
+			}
+
+			assert.DeepEqual(t, l.Segments(ctx), tc.want)
+			assert.NilError(t, l.Validate())
+		})
+	}
+}
+
+func TestLog_WithPurgeHandler(t *testing.T) {
+	type ctxKey struct{}
+
+	var purged [][]memlog.Offset
+	handler := func(ctx context.Context, seg []memlog.Record) {
+		assert.Equal(t, ctx.Value(ctxKey{}), "write")
+
+		offsets := make([]memlog.Offset, len(seg))
+		for i, r := range seg {
+			offsets[i] = r.Metadata.Offset
+		}
+		purged = append(purged, offsets)
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "write")
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(3), memlog.WithPurgeBatch(2), memlog.WithPurgeHandler(handler))
+	assert.NilError(t, err)
+
+	// purges offsets [0-5]
+	for _, d := range memlog.NewTestDataSlice(t, 10) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	assert.DeepEqual(t, purged, [][]memlog.Offset{{0, 1, 2}, {3, 4, 5}})
+}
+
+func TestLog_WriteInterceptor(t *testing.T) {
+	ctx := context.Background()
+
+	// keep every second record, redact and fail on special payloads
+	interceptor := func(ctx context.Context, data []byte) ([]byte, error) {
+		switch string(data) {
+		case "fail":
+			return nil, errors.New("rejected")
+		case "redact":
+			return []byte("xxxxxxxxxxxxxxx"), nil
+		}
+
+		next, ok := memlog.WriteOffset(ctx)
+		if !ok {
+			return nil, errors.New("missing write offset")
+		}
+		if next%2 != 0 {
+			return nil, memlog.ErrDropped
+		}
+		return nil, nil
+	}
+
+	l, err := memlog.New(ctx, memlog.WithWriteInterceptor(interceptor), memlog.WithMaxRecordDataSize(10))
+	assert.NilError(t, err)
+
+	offset, written, err := l.TryWrite(ctx, []byte("keep"))
+	assert.NilError(t, err)
+	assert.Assert(t, written)
+	assert.Equal(t, offset, memlog.Offset(0))
+
+	offset, written, err = l.TryWrite(ctx, []byte("drop"))
+	assert.NilError(t, err)
+	assert.Assert(t, !written)
+	assert.Equal(t, offset, memlog.Offset(1))
+
+	offset, err = l.Write(ctx, []byte("drop"))
+	assert.NilError(t, err)
+	assert.Equal(t, offset, memlog.Offset(1))
+
+	_, written, err = l.TryWrite(ctx, []byte("fail"))
+	assert.ErrorContains(t, err, "rejected")
+	assert.Assert(t, !written)
+
+	// max record size applies to intercepted data
+	_, written, err = l.TryWrite(ctx, []byte("redact"))
+	assert.Assert(t, errors.Is(err, memlog.ErrRecordTooLarge))
+	assert.Assert(t, !written)
+
+	_, latest := l.Range(ctx)
+	assert.Equal(t, latest, memlog.Offset(0))
+}
+
+func TestLog_WriteInterceptor_Chain(t *testing.T) {
+	type tenantKey struct{}
+	ctx := context.WithValue(context.Background(), tenantKey{}, "a")
+
+	validate := func(_ context.Context, data []byte) ([]byte, error) {
+		if !bytes.HasPrefix(data, []byte("{")) {
+			return nil, errors.New("invalid json")
+		}
+		return nil, nil
+	}
+
+	var calls []string
+	enrich := func(ctx context.Context, data []byte) ([]byte, error) {
+		calls = append(calls, "enrich:"+ctx.Value(tenantKey{}).(string))
+		return append([]byte(`{"source":"test",`), data[1:]...), nil
+	}
+
+	redact := func(ctx context.Context, data []byte) ([]byte, error) {
+		calls = append(calls, "redact:"+ctx.Value(tenantKey{}).(string))
+		return bytes.ReplaceAll(data, []byte("secret"), []byte("******")), nil
+	}
+
+	l, err := memlog.New(ctx,
+		memlog.WithWriteInterceptor(validate),
+		memlog.WithWriteInterceptor(enrich),
+		memlog.WithWriteInterceptor(redact),
+	)
+	assert.NilError(t, err)
+
+	offset, err := l.Write(ctx, []byte(`{"password":"secret"}`))
+	assert.NilError(t, err)
+
+	r, err := l.Read(ctx, offset)
+	assert.NilError(t, err)
+	assert.Equal(t, string(r.Data), `{"source":"test","password":"******"}`)
+	assert.DeepEqual(t, calls, []string{"enrich:a", "redact:a"})
+
+	// rejected by first interceptor
+	_, err = l.Write(ctx, []byte("secret"))
+	assert.ErrorContains(t, err, "invalid json")
+	assert.DeepEqual(t, calls, []string{"enrich:a", "redact:a"})
+}
+
+func TestLog_ReadInterceptor(t *testing.T) {
+	ctx := context.Background()
+
+	// hide records of other tenants
+	filter := func(_ context.Context, r memlog.Record) (memlog.Record, error) {
+		if r.Metadata.Extensions.Attributes["tenant"] != "a" {
+			return memlog.Record{}, memlog.ErrFiltered
+		}
+		return r, nil
+	}
+
+	upper := func(_ context.Context, r memlog.Record) (memlog.Record, error) {
+		r.Data = bytes.ToUpper(r.Data)
+		return r, nil
+	}
+
+	l, err := memlog.New(ctx, memlog.WithReadInterceptor(filter), memlog.WithReadInterceptor(upper))
+	assert.NilError(t, err)
+
+	for _, tenant := range []string{"a", "b", "a"} {
+		_, err = l.WriteRecord(ctx, memlog.Record{
+			Metadata: memlog.Header{Extensions: &memlog.Extensions{Attributes: map[string]string{"tenant": tenant}}},
+			Data:     []byte("data-" + tenant),
+		})
+		assert.NilError(t, err)
+	}
+
+	r, err := l.Read(ctx, 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, r.Data, []byte("DATA-A"))
+
+	_, err = l.Read(ctx, 1)
+	assert.ErrorIs(t, err, memlog.ErrFiltered)
+
+	batch := make([]memlog.Record, 3)
+	count, err := l.ReadBatch(ctx, 0, batch)
+	assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+	assert.Equal(t, count, 2)
+	assert.Equal(t, batch[1].Metadata.Offset, memlog.Offset(2))
+
+	// range reads skip filtered records
+	b, err := l.ReadRangeBytes(ctx, 0, 2)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, b, []byte("DATA-ADATA-A"))
+}
+
+func TestLog_DeadLetters(t *testing.T) {
+	ctx := context.Background()
+
+	l, err := memlog.New(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, l.DeadLetters() == nil)
+
+	reject := func(_ context.Context, data []byte) ([]byte, error) {
+		if string(data) == "invalid" {
+			return nil, errors.New("invalid data")
+		}
+		return nil, nil
+	}
+
+	opts := []memlog.Option{
+		memlog.WithDeadLetter(2),
+		memlog.WithWriteInterceptor(reject),
+		memlog.WithMaxRecordDataSize(10),
+	}
+	l, err = memlog.New(ctx, opts...)
+	assert.NilError(t, err)
+
+	_, err = l.Write(ctx, []byte("valid"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(l.DeadLetters()), 0)
+
+	_, err = l.Write(ctx, []byte("invalid"))
+	assert.ErrorContains(t, err, "invalid data")
+
+	_, err = l.Write(ctx, []byte("too large data"))
+	assert.Assert(t, errors.Is(err, memlog.ErrRecordTooLarge))
+
+	dead := l.DeadLetters()
+	assert.Equal(t, len(dead), 2)
+	assert.Equal(t, string(dead[0].Data), "invalid")
+	assert.Equal(t, string(dead[1].Data), "too large data")
+	assert.Equal(t, dead[0].Metadata.Offset, memlog.InvalidOffset)
+
+	// oldest is dropped
+	_, err = l.Write(ctx, []byte("also too large"))
+	assert.Assert(t, errors.Is(err, memlog.ErrRecordTooLarge))
+
+	dead = l.DeadLetters()
+	assert.Equal(t, len(dead), 2)
+	assert.Equal(t, string(dead[0].Data), "too large data")
+	assert.Equal(t, string(dead[1].Data), "also too large")
+
+	// copies are returned
+	dead[0].Data[0] = 'x'
+	assert.Equal(t, string(l.DeadLetters()[0].Data), "too large data")
+}
+
+func TestLog_ReadUntilTime(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewMock()
+	start := c.Now().UTC()
+
+	l, err := memlog.New(ctx, memlog.WithClock(c))
+	assert.NilError(t, err)
+
+	// one record per second
+	for _, d := range memlog.NewTestDataSlice(t, 10) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+		c.Add(time.Second)
+	}
+
+	testCases := []struct {
+		name      string
+		from      memlog.Offset
+		until     time.Time
+		batchSize int
+		want      int
+		wantErr   error
+	}{
+		{"stops at time boundary", 0, start.Add(5 * time.Second), 10, 5, nil},
+		{"boundary before first record", 2, start.Add(time.Second), 10, 0, nil},
+		{"batch full before boundary", 0, start.Add(5 * time.Second), 3, 3, nil},
+		{"end of log before boundary", 5, start.Add(time.Hour), 10, 5, memlog.ErrFutureOffset},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batch := make([]memlog.Record, tc.batchSize)
+			count, err := l.ReadUntilTime(ctx, tc.from, tc.until, batch)
+			if tc.wantErr != nil {
+				assert.Assert(t, errors.Is(err, tc.wantErr))
+			} else {
+				assert.NilError(t, err)
+			}
+			assert.Equal(t, count, tc.want)
+
+			for i := 0; i < count; i++ {
+				assert.Equal(t, batch[i].Metadata.Offset, tc.from+memlog.Offset(i))
+				assert.Assert(t, batch[i].Metadata.Created.Before(tc.until))
+			}
+		})
+	}
+}
+
+func TestLog_ReadRange(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewMock()
+	start := c.Now().UTC()
+
+	l, err := memlog.New(ctx, memlog.WithClock(c))
+	assert.NilError(t, err)
+
+	// one record per second
+	for _, d := range memlog.NewTestDataSlice(t, 10) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+		c.Add(time.Second)
+	}
+
+	testCases := []struct {
+		name      string
+		from, to  time.Time
+		batchSize int
+		wantStart memlog.Offset
+		want      int
+		wantErr   error
+	}{
+		{"reads records in interval", start.Add(2 * time.Second), start.Add(5 * time.Second), 10, 2, 3, nil},
+		{"from before first record", start.Add(-time.Hour), start.Add(2 * time.Second), 10, 0, 2, nil},
+		{"batch full before end of interval", start.Add(2 * time.Second), start.Add(8 * time.Second), 3, 2, 3, nil},
+		{"end of log before end of interval", start.Add(8 * time.Second), start.Add(time.Hour), 10, 8, 2, memlog.ErrFutureOffset},
+		{"interval after last record", start.Add(time.Hour), start.Add(2 * time.Hour), 10, 0, 0, memlog.ErrFutureOffset},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batch := make([]memlog.Record, tc.batchSize)
+			count, err := l.ReadRange(ctx, tc.from, tc.to, batch)
+			if tc.wantErr != nil {
+				assert.Assert(t, errors.Is(err, tc.wantErr))
+			} else {
+				assert.NilError(t, err)
+			}
+			assert.Equal(t, count, tc.want)
+
+			for i := 0; i < count; i++ {
+				assert.Equal(t, batch[i].Metadata.Offset, tc.wantStart+memlog.Offset(i))
+			}
+		})
+	}
+
+	t.Run("fails with invalid interval", func(t *testing.T) {
+		_, err := l.ReadRange(ctx, start, start, make([]memlog.Record, 1))
+		assert.ErrorContains(t, err, "from must be before to")
+	})
+}
+
+func TestLog_VerifyContiguous(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	for _, d := range memlog.NewTestDataSlice(t, 25) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	testCases := []struct {
+		name    string
+		from    memlog.Offset
+		to      memlog.Offset
+		wantOK  bool
+		wantErr string
+	}{
+		{name: "contiguous across segments", from: 10, to: 24, wantOK: true},
+		{name: "single offset", from: 15, to: 15, wantOK: true},
+		{name: "fails on invalid interval", from: 20, to: 10, wantErr: "must not be greater"},
+		{name: "fails on purged offset", from: 0, to: 15, wantErr: memlog.ErrOutOfRange.Error()},
+		{name: "fails on future offset", from: 20, to: 25, wantErr: memlog.ErrFutureOffset.Error()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gap, ok, err := l.VerifyContiguous(ctx, tc.from, tc.to)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+			assert.Equal(t, ok, tc.wantOK)
+			assert.Equal(t, gap, memlog.Offset(-1))
+		})
+	}
+}
+
+func TestLog_Age(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewMock()
+
+	l, err := memlog.New(ctx, memlog.WithClock(c))
+	assert.NilError(t, err)
+
+	offset, err := l.Write(ctx, []byte("data"))
+	assert.NilError(t, err)
+
+	c.Add(time.Minute)
+	age, err := l.Age(ctx, offset)
+	assert.NilError(t, err)
+	assert.Equal(t, age, time.Minute)
+
+	age, err = l.Age(ctx, offset+1)
+	assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+	assert.Equal(t, age, time.Duration(0))
+}
+
+func TestLog_MonotonicTimestamps(t *testing.T) {
+	testCases := []struct {
+		name      string
+		monotonic bool
+	}{
+		{name: "clock goes backwards, timestamps regress", monotonic: false},
+		{name: "clock goes backwards, timestamps are clamped", monotonic: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := clock.NewMock()
+			c.Add(time.Hour)
+
+			opts := []memlog.Option{memlog.WithClock(c)}
+			if tc.monotonic {
+				opts = append(opts, memlog.WithMonotonicTimestamps())
+			}
+
+			l, err := memlog.New(ctx, opts...)
+			assert.NilError(t, err)
+
+			first, err := l.Write(ctx, []byte("first"))
+			assert.NilError(t, err)
+
+			c.Add(-time.Minute)
+			second, err := l.Write(ctx, []byte("second"))
+			assert.NilError(t, err)
+
+			r1, err := l.Read(ctx, first)
+			assert.NilError(t, err)
+			r2, err := l.Read(ctx, second)
+			assert.NilError(t, err)
+
+			if tc.monotonic {
+				assert.Equal(t, r2.Metadata.Created, r1.Metadata.Created)
+			} else {
+				assert.Equal(t, r2.Metadata.Created, r1.Metadata.Created.Add(-time.Minute))
+			}
+		})
+	}
+}
+
+func TestLog_WriteAt(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("fails with zero creation time", func(t *testing.T) {
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		_, err = l.WriteAt(ctx, time.Time{}, []byte("data"))
+		assert.ErrorContains(t, err, "must not be zero")
+	})
+
+	t.Run("writes with creation time", func(t *testing.T) {
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		offset, err := l.WriteAt(ctx, created.In(time.FixedZone("CET", 3600)), []byte("data"))
+		assert.NilError(t, err)
+
+		r, err := l.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.Equal(t, r.Metadata.Created, created)
+	})
+
+	t.Run("strict time ordering", func(t *testing.T) {
+		l, err := memlog.New(ctx, memlog.WithStrictTimeOrdering(), memlog.WithMonotonicTimestamps())
+		assert.NilError(t, err)
+
+		_, err = l.WriteAt(ctx, created, []byte("first"))
+		assert.NilError(t, err)
+
+		// equal creation time is allowed
+		offset, err := l.WriteAt(ctx, created, []byte("second"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(1))
+
+		offset, err = l.WriteAt(ctx, created.Add(-time.Nanosecond), []byte("third"))
+		assert.Assert(t, errors.Is(err, memlog.ErrNonMonotonicTime))
+		assert.Equal(t, offset, memlog.InvalidOffset)
+
+		_, latest := l.Range(ctx)
+		assert.Equal(t, latest, memlog.Offset(1))
+	})
+
+	t.Run("clamps without strict time ordering", func(t *testing.T) {
+		l, err := memlog.New(ctx, memlog.WithMonotonicTimestamps())
+		assert.NilError(t, err)
+
+		_, err = l.WriteAt(ctx, created, []byte("first"))
+		assert.NilError(t, err)
+
+		offset, err := l.WriteAt(ctx, created.Add(-time.Minute), []byte("second"))
+		assert.NilError(t, err)
+
+		r, err := l.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.Equal(t, r.Metadata.Created, created)
+	})
+}
+
+func TestLog_WriteExpect(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithStartOffset(10))
+	assert.NilError(t, err)
+
+	t.Run("fails on conflict", func(t *testing.T) {
+		offset, err := l.WriteExpect(ctx, 0, []byte("data"))
+		assert.Assert(t, errors.Is(err, memlog.ErrOffsetConflict))
+		assert.Equal(t, offset, memlog.InvalidOffset)
+
+		var conflict *memlog.OffsetConflictError
+		assert.Assert(t, errors.As(err, &conflict))
+		assert.Equal(t, conflict.Expected, memlog.Offset(0))
+		assert.Equal(t, conflict.Next, memlog.Offset(10))
+	})
+
+	t.Run("writes at expected offset", func(t *testing.T) {
+		offset, err := l.WriteExpect(ctx, 10, []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(10))
+	})
+
+	t.Run("only one concurrent writer succeeds", func(t *testing.T) {
+		const writers = 10
+
+		var (
+			eg        errgroup.Group
+			written   int32
+			conflicts int32
+		)
+
+		for i := 0; i < writers; i++ {
+			eg.Go(func() error {
+				_, err := l.WriteExpect(ctx, 11, []byte("data"))
+				switch {
+				case err == nil:
+					atomic.AddInt32(&written, 1)
+				case errors.Is(err, memlog.ErrOffsetConflict):
+					atomic.AddInt32(&conflicts, 1)
+				default:
+					return err
+				}
+				return nil
+			})
+		}
+		assert.NilError(t, eg.Wait())
+
+		assert.Equal(t, written, int32(1))
+		assert.Equal(t, conflicts, int32(writers-1))
+
+		_, latest := l.Range(ctx)
+		assert.Equal(t, latest, memlog.Offset(11))
+	})
+}
+
+func TestLog_ReadMany(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	for _, d := range memlog.NewTestDataSlice(t, 25) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	t.Run("reads non-contiguous offsets in order", func(t *testing.T) {
+		offsets := []memlog.Offset{24, 10, 17, 10}
+		records, err := l.ReadMany(ctx, offsets)
+		assert.NilError(t, err)
+		assert.Equal(t, len(records), len(offsets))
+		for i, r := range records {
+			assert.Equal(t, r.Metadata.Offset, offsets[i])
+		}
+	})
+
+	t.Run("fails fast on invalid offset", func(t *testing.T) {
+		records, err := l.ReadMany(ctx, []memlog.Offset{10, 5, 30})
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+		assert.ErrorContains(t, err, "read offset 5")
+		assert.Assert(t, records == nil)
+	})
+}
+
+func TestLog_WriteV(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxRecordDataSize(10))
+	assert.NilError(t, err)
+
+	t.Run("writes concatenated data", func(t *testing.T) {
+		offset, err := l.WriteV(ctx, []byte("head"), nil, []byte("-"), []byte("body"))
+		assert.NilError(t, err)
+
+		r, err := l.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, []byte("head-body"))
+	})
+
+	t.Run("does not retain buffers", func(t *testing.T) {
+		buf := []byte("data")
+		offset, err := l.WriteV(ctx, buf)
+		assert.NilError(t, err)
+
+		copy(buf, "xxxx")
+		r, err := l.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, []byte("data"))
+	})
+
+	t.Run("fails when concatenated data too large", func(t *testing.T) {
+		offset, err := l.WriteV(ctx, []byte("header"), []byte("body"), []byte("!"))
+		assert.ErrorIs(t, err, memlog.ErrRecordTooLarge)
+		assert.Equal(t, offset, memlog.InvalidOffset)
+	})
+
+	t.Run("fails without data", func(t *testing.T) {
+		offset, err := l.WriteV(ctx, nil, []byte{})
+		assert.ErrorContains(t, err, "no data provided")
+		assert.Equal(t, offset, memlog.InvalidOffset)
+	})
+}
+
+func TestLog_WriteBatch(t *testing.T) {
+	t.Run("writes contiguous offsets across segments", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(4))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("first"))
+		assert.NilError(t, err)
+
+		offsets, err := l.WriteBatch(ctx, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, offsets, []memlog.Offset{1, 2, 3, 4})
+
+		r, err := l.Read(ctx, 4)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, []byte("d"))
+		assert.Equal(t, len(l.Segments(ctx)), 2)
+		assert.NilError(t, l.Validate())
+	})
+
+	t.Run("writes nothing if a record is invalid", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxRecordDataSize(4))
+		assert.NilError(t, err)
+
+		offsets, err := l.WriteBatch(ctx, [][]byte{[]byte("a"), []byte("too large")})
+		assert.ErrorIs(t, err, memlog.ErrRecordTooLarge)
+		assert.Assert(t, offsets == nil)
+
+		offsets, err = l.WriteBatch(ctx, [][]byte{[]byte("a"), nil})
+		assert.ErrorContains(t, err, "no data provided")
+		assert.Assert(t, offsets == nil)
+
+		earliest, _ := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.InvalidOffset)
+	})
+
+	t.Run("fails with invalid batch size", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2))
+		assert.NilError(t, err)
+
+		_, err = l.WriteBatch(ctx, nil)
+		assert.ErrorContains(t, err, "batch must not be empty")
+
+		_, err = l.WriteBatch(ctx, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+		assert.ErrorContains(t, err, "not larger than the segment size")
+	})
+
+	t.Run("fails with write interceptor", func(t *testing.T) {
+		ctx := context.Background()
+		keep := func(_ context.Context, data []byte) ([]byte, error) {
+			return nil, nil
+		}
+		l, err := memlog.New(ctx, memlog.WithWriteInterceptor(keep))
+		assert.NilError(t, err)
+
+		_, err = l.WriteBatch(ctx, [][]byte{[]byte("a")})
+		assert.ErrorContains(t, err, "not supported with write interceptors")
+	})
+}
+
+func TestLog_WriteRecord(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx)
+	assert.NilError(t, err)
+
+	attrs := map[string]string{"content-type": "application/json", "trace-id": "abc"}
+	offset, err := l.WriteRecord(ctx, memlog.Record{
+		Metadata: memlog.Header{
+			Offset:     10, // ignored
+			ID:         "1",
+			Extensions: &memlog.Extensions{Attributes: attrs},
+		},
+		Data: []byte(`{"id":"1"}`),
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, offset, memlog.Offset(0))
+
+	// not retained by the log
+	attrs["trace-id"] = "modified"
+
+	want := map[string]string{"content-type": "application/json", "trace-id": "abc"}
+
+	r, err := l.Read(ctx, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, r.Metadata.ID, "1")
+	assert.DeepEqual(t, r.Metadata.Extensions.Attributes, want)
+
+	// not shared with readers
+	r.Metadata.Extensions.Attributes["trace-id"] = "modified"
+
+	batch := make([]memlog.Record, 1)
+	count, err := l.ReadBatch(ctx, 0, batch)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+	assert.DeepEqual(t, batch[0].Metadata.Extensions.Attributes, want)
+
+	t.Run("fails without data", func(t *testing.T) {
+		_, err := l.WriteRecord(ctx, memlog.Record{Metadata: memlog.Header{Extensions: &memlog.Extensions{Attributes: want}}})
+		assert.ErrorContains(t, err, "no data provided")
+	})
+}
+
+func TestLog_ReadBatch_EmptyBatch(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithStartOffset(5), memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	// purges offsets [5-14]
+	for _, d := range memlog.NewTestDataSlice(t, 25) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	testCases := []struct {
+		name    string
+		offset  memlog.Offset
+		wantErr error
+	}{
+		{name: "readable offset", offset: 15},
+		{name: "latest offset", offset: 29},
+		{name: "purged offset", offset: 14, wantErr: memlog.ErrOutOfRange},
+		{name: "before start offset", offset: 0, wantErr: memlog.ErrOutOfRange},
+		{name: "future offset", offset: 30, wantErr: memlog.ErrFutureOffset},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, err := l.ReadBatch(ctx, tc.offset, nil)
+			assert.Equal(t, count, 0)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestLog_ReadBatchBytes(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	sizes := []int{4, 4, 8, 20, 2, 2}
+	for _, size := range sizes {
+		_, err = l.Write(ctx, bytes.Repeat([]byte("x"), size))
+		assert.NilError(t, err)
+	}
+
+	testCases := []struct {
+		name     string
+		start    memlog.Offset
+		maxBytes int
+		want     []memlog.Offset
+		next     memlog.Offset
+		wantErr  error
+	}{
+		{name: "stops before exceeding budget", start: 0, maxBytes: 10, want: []memlog.Offset{0, 1}, next: 2},
+		{name: "fills budget exactly", start: 0, maxBytes: 16, want: []memlog.Offset{0, 1, 2}, next: 3},
+		{name: "returns single record exceeding budget", start: 3, maxBytes: 10, want: []memlog.Offset{3}, next: 4},
+		{name: "returns records at end of log", start: 4, maxBytes: 100, want: []memlog.Offset{4, 5}, next: 6, wantErr: memlog.ErrFutureOffset},
+		{name: "fails on future offset", start: 6, maxBytes: 100, next: 6, wantErr: memlog.ErrFutureOffset},
+		{name: "fails on invalid offset", start: -1, maxBytes: 100, next: -1, wantErr: memlog.ErrOutOfRange},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			records, next, err := l.ReadBatchBytes(ctx, tc.start, tc.maxBytes)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+
+			assert.Equal(t, next, tc.next)
+			assert.Equal(t, len(records), len(tc.want))
+			for i, r := range records {
+				assert.Equal(t, r.Metadata.Offset, tc.want[i])
+			}
+		})
+	}
+
+	t.Run("fails with invalid budget", func(t *testing.T) {
+		_, _, err := l.ReadBatchBytes(ctx, 0, 0)
+		assert.ErrorContains(t, err, "must be greater than 0")
+	})
+}
+
+func TestLog_WithRetention(t *testing.T) {
+	t.Run("purges expired segments on write", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(10), memlog.WithPurgeBatch(5), memlog.WithRetention(time.Hour))
+		assert.NilError(t, err)
+
+		// offsets [0-9] at 0m, [10-19] at 30m, [20-24] at 60m
+		for i := 0; i < 25; i++ {
+			c.Set(time.Unix(0, 0).Add(time.Duration(i/10) * 30 * time.Minute))
+			_, err = l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+		}
+
+		earliest, _ := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(0))
+
+		// purges offsets [0-9] although history is not full
+		c.Set(time.Unix(0, 0).Add(61 * time.Minute))
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		earliest, latest := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(10))
+		assert.Equal(t, latest, memlog.Offset(25))
+		assert.Assert(t, l.Purged())
+	})
+
+	t.Run("purges all records including active segment", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(10), memlog.WithRetention(time.Hour))
+		assert.NilError(t, err)
+
+		for i := 0; i < 15; i++ {
+			_, err = l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+		}
+
+		// no writes
+		c.Add(2 * time.Hour)
+		assert.NilError(t, l.Expire(ctx))
+
+		earliest, latest := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.InvalidOffset)
+		assert.Equal(t, latest, memlog.InvalidOffset)
+		assert.NilError(t, l.Validate())
+
+		_, err = l.Read(ctx, 14)
+		assert.ErrorIs(t, err, memlog.ErrOutOfRange)
+
+		// offsets continue
+		offset, err := l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(15))
+
+		earliest, latest = l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(15))
+		assert.Equal(t, latest, memlog.Offset(15))
+	})
+	t.Run("does not expire segments by unwritten reserved offsets", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(10), memlog.WithRetention(time.Hour))
+		assert.NilError(t, err)
+
+		c.Add(2 * time.Hour)
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		start, err := l.Reserve(ctx, 1)
+		assert.NilError(t, err)
+
+		c.Add(time.Second)
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		earliest, _ := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(0))
+		assert.NilError(t, l.WriteReserved(ctx, start, []byte("reserved")))
+
+		// segment expires by its latest written record
+		_, err = l.Reserve(ctx, 1)
+		assert.NilError(t, err)
+		c.Add(2 * time.Hour)
+		assert.NilError(t, l.Expire(ctx))
+
+		earliest, _ = l.Range(ctx)
+		assert.Equal(t, earliest, memlog.InvalidOffset)
+	})
+}
+
+func TestLog_Truncate(t *testing.T) {
+	newLog := func(t *testing.T) *memlog.Log {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithStartOffset(5), memlog.WithMaxSegmentSize(10), memlog.WithMaxHistorySegments(3))
+		assert.NilError(t, err)
+
+		// offsets [5-34] in 3 segments
+		for i := 0; i < 30; i++ {
+			_, err = l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+		}
+		return l
+	}
+
+	t.Run("fails with future offset", func(t *testing.T) {
+		ctx := context.Background()
+		l := newLog(t)
+
+		err := l.Truncate(ctx, 36)
+		assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+	})
+
+	t.Run("discards records before offset", func(t *testing.T) {
+		ctx := context.Background()
+		l := newLog(t)
+
+		assert.NilError(t, l.Truncate(ctx, 18))
+
+		earliest, latest := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(18))
+		assert.Equal(t, latest, memlog.Offset(34))
+		assert.Assert(t, l.Purged())
+
+		_, err := l.Read(ctx, 17)
+		assert.ErrorIs(t, err, memlog.ErrOutOfRange)
+
+		r, err := l.Read(ctx, 18)
+		assert.NilError(t, err)
+		assert.Equal(t, r.Metadata.Offset, memlog.Offset(18))
+
+		// segment [5-14] released
+		assert.Equal(t, len(l.Segments(ctx)), 2)
+		assert.Equal(t, l.Stats(ctx).Records, 17)
+
+		v, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+		earliest, _ = v.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(18))
+
+		// truncating before an earlier offset has no effect
+		assert.NilError(t, l.Truncate(ctx, 10))
+		earliest, _ = l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(18))
+	})
+
+	t.Run("discards all records", func(t *testing.T) {
+		ctx := context.Background()
+		l := newLog(t)
+
+		assert.NilError(t, l.Truncate(ctx, 35))
+
+		earliest, latest := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.InvalidOffset)
+		assert.Equal(t, latest, memlog.InvalidOffset)
+		assert.NilError(t, l.Validate())
+
+		offset, err := l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(35))
+
+		earliest, _ = l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(35))
+	})
+
+	t.Run("evicts idempotency keys of discarded records", func(t *testing.T) {
+		ctx := context.Background()
+		keyFn := func(data []byte) []byte { return data }
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10), memlog.WithDedupeKeyFunc(keyFn))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("first"))
+		assert.NilError(t, err)
+		_, err = l.WriteWithID(ctx, "id", []byte("second"))
+		assert.NilError(t, err)
+
+		// truncated records are retained in the active segment
+		assert.NilError(t, l.Truncate(ctx, 2))
+
+		offset, err := l.Write(ctx, []byte("first"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(2))
+
+		offset, err = l.WriteWithID(ctx, "id", []byte("second"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(3))
+
+		_, err = l.Read(ctx, offset)
+		assert.NilError(t, err)
+	})
+}
+
+func TestLog_WriteTTL(t *testing.T) {
+	t.Run("fails with invalid ttl", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		_, err = l.WriteTTL(ctx, 0, []byte("data"))
+		assert.ErrorContains(t, err, "ttl must be greater than 0")
+	})
+
+	t.Run("expired records are not readable", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		_, err = l.WriteTTL(ctx, time.Minute, []byte("ephemeral"))
+		assert.NilError(t, err)
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		r, err := l.Read(ctx, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, *r.Metadata.Extensions.Expires, c.Now().UTC().Add(time.Minute))
+
+		v, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+
+		c.Add(time.Minute)
+		_, err = l.Read(ctx, 0)
+		assert.ErrorIs(t, err, memlog.ErrOutOfRange)
+
+		// batch reads skip expired records
+		batch := make([]memlog.Record, 10)
+		count, err := l.ReadBatch(ctx, 0, batch)
+		assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+		assert.Equal(t, count, 1)
+		assert.Equal(t, batch[0].Metadata.Offset, memlog.Offset(1))
+
+		// views are pinned to the snapshot time
+		_, err = v.Read(ctx, 0)
+		assert.NilError(t, err)
+	})
+
+	t.Run("purges segments with only expired records", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		// offsets [0-9] expire, [10-14] do not
+		for i := 0; i < 15; i++ {
+			if i < 10 {
+				_, err = l.WriteTTL(ctx, time.Minute, []byte("data"))
+			} else {
+				_, err = l.Write(ctx, []byte("data"))
+			}
+			assert.NilError(t, err)
+		}
+
+		assert.NilError(t, l.Expire(ctx))
+		earliest, _ := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(0))
+
+		c.Add(time.Minute)
+		assert.NilError(t, l.Expire(ctx))
+
+		earliest, latest := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(10))
+		assert.Equal(t, latest, memlog.Offset(14))
+		assert.NilError(t, l.Validate())
+	})
+
+	t.Run("expiry is only serialized for records with ttl", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		_, err = l.WriteTTL(ctx, time.Minute, []byte("ephemeral"))
+		assert.NilError(t, err)
+
+		for offset, want := range []bool{false, true} {
+			r, err := l.Read(ctx, memlog.Offset(offset))
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Extensions != nil, want)
+
+			b, err := json.Marshal(r)
+			assert.NilError(t, err)
+			assert.Equal(t, strings.Contains(string(b), `"expires"`), want)
+		}
+	})
+}
+
+func TestLog_OffsetSentinels(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2))
+	assert.NilError(t, err)
+
+	_, err = l.Read(ctx, memlog.OffsetEarliest)
+	assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+
+	// purges offsets [0-1]
+	for i := 0; i < 5; i++ {
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+	}
+
+	r, err := l.Read(ctx, memlog.OffsetEarliest)
+	assert.NilError(t, err)
+	assert.Equal(t, r.Metadata.Offset, memlog.Offset(2))
+
+	r, err = l.Read(ctx, memlog.OffsetLatest)
+	assert.NilError(t, err)
+	assert.Equal(t, r.Metadata.Offset, memlog.Offset(4))
+
+	batch := make([]memlog.Record, 5)
+	count, err := l.ReadBatch(ctx, memlog.OffsetEarliest, batch)
+	assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+	assert.Equal(t, count, 3)
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream := l.Stream(streamCtx, memlog.OffsetLatest)
+	r, ok := stream.Next()
+	assert.Assert(t, ok)
+	assert.Equal(t, r.Metadata.Offset, memlog.Offset(4))
+}
+
+func TestLog_Recover(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	offset, err := l.Recover(ctx)
+	assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+	assert.Equal(t, offset, memlog.InvalidOffset)
+
+	// purges offsets [0-9]
+	for _, d := range memlog.NewTestDataSlice(t, 25) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	// slow reader
+	_, err = l.Read(ctx, 5)
+	assert.ErrorIs(t, err, memlog.ErrOutOfRange)
+
+	offset, err = l.Recover(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, offset, memlog.Offset(10))
+
+	r, err := l.Read(ctx, offset)
+	assert.NilError(t, err)
+	assert.Equal(t, r.Metadata.Offset, offset)
+}
+
+func TestLog_ReadPrev(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithStartOffset(5), memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	// purges offsets [5-14]
+	for _, d := range memlog.NewTestDataSlice(t, 25) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	testCases := []struct {
+		name    string
+		offset  memlog.Offset
+		want    memlog.Offset
+		wantErr error
+	}{
+		{name: "predecessor available", offset: 16, want: 15},
+		{name: "next write offset returns latest", offset: 30, want: 29},
+		{name: "predecessor purged", offset: 15, wantErr: memlog.ErrOutOfRange},
+		{name: "start offset", offset: 5, wantErr: memlog.ErrOutOfRange},
+		{name: "underflow", offset: math.MinInt, wantErr: memlog.ErrOutOfRange},
+		{name: "predecessor in future", offset: 31, wantErr: memlog.ErrFutureOffset},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := l.ReadPrev(ctx, tc.offset)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.DeepEqual(t, r, memlog.Record{})
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Offset, tc.want)
+		})
+	}
+}
+
+func TestLog_WriteRate(t *testing.T) {
+	t.Run("returns 0 when rate tracking is disabled", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, l.WriteRate(), float64(0))
+	})
+
+	t.Run("tracks writes in sliding window", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithRateTracker(10*time.Second))
+		assert.NilError(t, err)
+
+		// 5 writes per second for 4 seconds
+		for i := 0; i < 4; i++ {
+			for j := 0; j < 5; j++ {
+				_, err = l.Write(ctx, []byte("data"))
+				assert.NilError(t, err)
+			}
+			c.Add(time.Second)
+		}
+		assert.Equal(t, l.WriteRate(), float64(2))
+
+		// first 2 seconds slide out of the window
+		c.Add(7 * time.Second)
+		assert.Equal(t, l.WriteRate(), float64(1))
+
+		c.Add(time.Minute)
+		assert.Equal(t, l.WriteRate(), float64(0))
+	})
+}
+
+func TestLog_Warm(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	// empty log
+	assert.NilError(t, l.Warm(ctx))
+
+	for _, d := range memlog.NewTestDataSlice(t, 15) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, l.Warm(ctx))
+
+	cancel()
+	assert.Assert(t, errors.Is(l.Warm(ctx), context.Canceled))
+}
+
+func TestLog_DedupeKeyFunc(t *testing.T) {
+	ctx := context.Background()
+
+	// records with the same id are the same logical record
+	keyFn := func(data []byte) []byte {
+		var d struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(data, &d); err != nil {
+			return data
+		}
+		return []byte(d.ID)
+	}
+
+	l, err := memlog.New(ctx, memlog.WithDedupeKeyFunc(keyFn), memlog.WithMaxSegmentSize(2))
+	assert.NilError(t, err)
+
+	offset, written, err := l.TryWrite(ctx, []byte(`{"id":"1","time":"10:00"}`))
+	assert.NilError(t, err)
+	assert.Assert(t, written)
+	assert.Equal(t, offset, memlog.Offset(0))
+
+	// duplicate with different irrelevant fields
+	offset, written, err = l.TryWrite(ctx, []byte(`{"id":"1","time":"10:01"}`))
+	assert.NilError(t, err)
+	assert.Assert(t, !written)
+	assert.Equal(t, offset, memlog.Offset(0))
+
+	for _, d := range []string{`{"id":"2"}`, `{"id":"3"}`, `{"id":"4"}`, `{"id":"5"}`} {
+		_, err = l.Write(ctx, []byte(d))
+		assert.NilError(t, err)
+	}
+
+	// record with id 1 is purged and can be written again
+	earliest, _ := l.Range(ctx)
+	assert.Equal(t, earliest, memlog.Offset(2))
+
+	offset, written, err = l.TryWrite(ctx, []byte(`{"id":"1"}`))
+	assert.NilError(t, err)
+	assert.Assert(t, written)
+	assert.Equal(t, offset, memlog.Offset(5))
+}
+
+func TestLog_WriteWithID(t *testing.T) {
+	t.Run("fails with empty id", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		_, err = l.WriteWithID(ctx, "", []byte("data"))
+		assert.ErrorContains(t, err, "id must not be empty")
+	})
+
+	t.Run("returns offset of retained record with same id", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2))
+		assert.NilError(t, err)
+
+		offset, err := l.WriteWithID(ctx, "1", []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(0))
+
+		// retry
+		offset, err = l.WriteWithID(ctx, "1", []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(0))
+
+		r, err := l.Read(ctx, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, r.Metadata.ID, "1")
+
+		for _, id := range []string{"2", "3", "4", "5"} {
+			_, err = l.WriteWithID(ctx, id, []byte("data"))
+			assert.NilError(t, err)
+		}
+
+		// record with id 1 is purged and can be written again
+		offset, err = l.WriteWithID(ctx, "1", []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(5))
+	})
+
+	t.Run("writes duplicates outside of dedupe window", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithDedupeWindow(time.Minute))
+		assert.NilError(t, err)
+
+		_, err = l.WriteWithID(ctx, "1", []byte("data"))
+		assert.NilError(t, err)
+
+		c.Add(59 * time.Second)
+		offset, err := l.WriteWithID(ctx, "1", []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(0))
+
+		c.Add(time.Second)
+		offset, err = l.WriteWithID(ctx, "1", []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(1))
+	})
+}
+
+func TestLog_Purged(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(5))
+	assert.NilError(t, err)
+
+	// fills active and history segment
+	for _, d := range memlog.NewTestDataSlice(t, 10) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+	assert.Assert(t, !l.Purged())
+
+	_, err = l.Write(ctx, []byte("data"))
+	assert.NilError(t, err)
+	assert.Assert(t, l.Purged())
+}
+
+func TestLog_ReadRangeBytes(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(3))
+	assert.NilError(t, err)
+
+	for _, d := range []string{"a", "bb", "ccc", "dddd", "eeeee"} {
+		_, err = l.Write(ctx, []byte(d))
+		assert.NilError(t, err)
+	}
+
+	testCases := []struct {
+		name    string
+		from    memlog.Offset
+		to      memlog.Offset
+		want    string
+		wantErr string
+	}{
+		{name: "single record", from: 2, to: 2, want: "ccc"},
+		{name: "across segments", from: 1, to: 4, want: "bbcccddddeeeee"},
+		{name: "fails on invalid interval", from: 3, to: 1, wantErr: "must not be greater"},
+		{name: "fails on future offset", from: 3, to: 5, wantErr: memlog.ErrFutureOffset.Error()},
+		{name: "fails on out of range offset", from: -1, to: 2, wantErr: memlog.ErrOutOfRange.Error()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := l.ReadRangeBytes(ctx, tc.from, tc.to)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.Assert(t, got == nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), tc.want)
+		})
+	}
+
+	t.Run("skips expired records", func(t *testing.T) {
+		c := clock.NewMock()
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithChecksumVerification())
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("a"))
+		assert.NilError(t, err)
+		_, err = l.WriteTTL(ctx, time.Minute, []byte("bb"))
+		assert.NilError(t, err)
+		_, err = l.Write(ctx, []byte("ccc"))
+		assert.NilError(t, err)
+
+		c.Add(time.Minute)
+		got, err := l.ReadRangeBytes(ctx, 0, 2)
+		assert.NilError(t, err)
+		assert.Equal(t, string(got), "accc")
+	})
+}
+
+func TestLog_GlobalSequence(t *testing.T) {
+	t.Run("sequence not set by default", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		offset, err := l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		r, err := l.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.Equal(t, r.Metadata.Seq, uint64(0))
+		assert.Equal(t, l.CurrentSequence(), uint64(0))
+	})
+
+	t.Run("stamps records with sequence across purges", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithGlobalSequence(1000), memlog.WithMaxSegmentSize(2), memlog.WithStartOffset(10))
+		assert.NilError(t, err)
+		assert.Equal(t, l.CurrentSequence(), uint64(1000))
+
+		for i := 0; i < 10; i++ {
+			offset, err := l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+
+			r, err := l.Read(ctx, offset)
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Seq, uint64(1000+i))
+		}
+
+		assert.Equal(t, l.CurrentSequence(), uint64(1010))
+	})
+}
+
+func TestLog_ActiveFillRatio(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(4))
+	assert.NilError(t, err)
+	assert.Equal(t, l.ActiveFillRatio(ctx), float64(0))
+
+	want := []float64{0.25, 0.5, 0.75, 1, 0.25}
+	for _, w := range want {
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, l.ActiveFillRatio(ctx), w)
+	}
+}
+
+func TestLog_WaitEmpty(t *testing.T) {
+	t.Run("returns on empty log", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+		assert.NilError(t, l.WaitEmpty(ctx))
+	})
+
+	t.Run("blocks until context is cancelled", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		// concurrent writes wake up the waiter
+		go func() {
+			for i := 0; i < 5; i++ {
+				_, writeErr := l.Write(context.Background(), []byte("data"))
+				assert.Check(t, writeErr)
+			}
+		}()
+
+		err = l.WaitEmpty(ctx)
+		assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("returns after default timeout", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithDefaultTimeout(10*time.Millisecond))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		err = l.WaitEmpty(ctx)
+		assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("default timeout does not override context deadline", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithDefaultTimeout(time.Millisecond))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err = l.WaitEmpty(ctx)
+		assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Assert(t, time.Since(start) >= 50*time.Millisecond)
+	})
+}
+
+func TestLog_OffsetForTime(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []memlog.Option
+	}{
+		{name: "binary search", opts: nil},
+		{name: "time index", opts: []memlog.Option{memlog.WithTimeIndex(5 * time.Second)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := clock.NewMock()
+			start := c.Now()
+
+			opts := append([]memlog.Option{memlog.WithClock(c), memlog.WithMaxSegmentSize(10)}, tc.opts...)
+			l, err := memlog.New(ctx, opts...)
+			assert.NilError(t, err)
+
+			_, err = l.OffsetForTime(ctx, start)
+			assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+
+			// one record every 2s, two records per timestamp
+			for i, d := range memlog.NewTestDataSlice(t, 30) {
+				_, err = l.Write(ctx, d)
+				assert.NilError(t, err)
+				if i%2 == 1 {
+					c.Add(2 * time.Second)
+				}
+			}
+
+			// offsets [0-9] purged
+			offset, err := l.OffsetForTime(ctx, start)
+			assert.NilError(t, err)
+			assert.Equal(t, offset, memlog.Offset(10))
+
+			offset, err = l.OffsetForTime(ctx, start.Add(20*time.Second))
+			assert.NilError(t, err)
+			assert.Equal(t, offset, memlog.Offset(20))
+
+			offset, err = l.OffsetForTime(ctx, start.Add(21*time.Second))
+			assert.NilError(t, err)
+			assert.Equal(t, offset, memlog.Offset(22))
+
+			offset, err = l.OffsetForTime(ctx, start.Add(28*time.Second))
+			assert.NilError(t, err)
+			assert.Equal(t, offset, memlog.Offset(28))
+
+			_, err = l.OffsetForTime(ctx, start.Add(29*time.Second))
+			assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+		})
+	}
+}
+
+func TestLog_ReadBatchLatest(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	batch := make([]memlog.Record, 5)
+
+	t.Run("fails when n is invalid", func(t *testing.T) {
+		_, err = l.ReadBatchLatest(ctx, 0, batch)
+		assert.ErrorContains(t, err, "must be greater than 0")
+	})
+
+	t.Run("empty log", func(t *testing.T) {
+		count, err := l.ReadBatchLatest(ctx, 3, batch)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 0)
+	})
+
+	// offsets [0-9] purged
+	for _, d := range memlog.NewTestDataSlice(t, 25) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	testCases := []struct {
+		name      string
+		n         int
+		batchSize int
+		wantFirst memlog.Offset
+		wantCount int
+	}{
+		{name: "latest 3 records", n: 3, batchSize: 5, wantFirst: 22, wantCount: 3},
+		{name: "n greater than batch", n: 8, batchSize: 5, wantFirst: 20, wantCount: 5},
+		{name: "n greater than retained records", n: 30, batchSize: 30, wantFirst: 10, wantCount: 15},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batch := make([]memlog.Record, tc.batchSize)
+			count, err := l.ReadBatchLatest(ctx, tc.n, batch)
+			assert.NilError(t, err)
+			assert.Equal(t, count, tc.wantCount)
+
+			for i, r := range batch[:count] {
+				assert.Equal(t, r.Metadata.Offset, tc.wantFirst+memlog.Offset(i))
+			}
+			assert.Equal(t, batch[count-1].Metadata.Offset, memlog.Offset(24))
+		})
+	}
+
+	t.Run("skips compacted records", func(t *testing.T) {
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(2), memlog.WithMaxHistorySegments(2), memlog.WithCompaction())
+		assert.NilError(t, err)
+
+		// offsets 0 and 1 are compacted
+		for _, key := range []string{"a", "b", "c", "d", "a", "b"} {
+			_, err = l.WriteKey(ctx, []byte(key), []byte("data-"+key))
+			assert.NilError(t, err)
+		}
+		assert.NilError(t, l.Compact(ctx))
+
+		batch := make([]memlog.Record, 10)
+		count, err := l.ReadBatchLatest(ctx, 6, batch)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 4)
+		assert.Equal(t, batch[0].Metadata.Offset, memlog.Offset(2))
+		assert.Equal(t, batch[count-1].Metadata.Offset, memlog.Offset(5))
+	})
+
+	t.Run("skips unwritten reserved offsets", func(t *testing.T) {
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("first"))
+		assert.NilError(t, err)
+		_, err = l.Reserve(ctx, 1)
+		assert.NilError(t, err)
+		_, err = l.Write(ctx, []byte("last"))
+		assert.NilError(t, err)
+
+		batch := make([]memlog.Record, 3)
+		count, err := l.ReadBatchLatest(ctx, 3, batch)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 2)
+		assert.Equal(t, batch[0].Metadata.Offset, memlog.Offset(0))
+		assert.Equal(t, batch[1].Metadata.Offset, memlog.Offset(2))
+	})
+}
+
+func TestLog_HeadTail(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	t.Run("fails when n is invalid", func(t *testing.T) {
+		_, err = l.Head(ctx, 0)
+		assert.ErrorContains(t, err, "must be greater than 0")
+
+		_, err = l.Tail(ctx, -1)
+		assert.ErrorContains(t, err, "must be greater than 0")
+	})
+
+	t.Run("empty log", func(t *testing.T) {
+		records, err := l.Head(ctx, 3)
+		assert.NilError(t, err)
+		assert.Equal(t, len(records), 0)
+
+		records, err = l.Tail(ctx, 3)
+		assert.NilError(t, err)
+		assert.Equal(t, len(records), 0)
+	})
+
+	// offsets [0-9] purged
+	for _, d := range memlog.NewTestDataSlice(t, 25) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	testCases := []struct {
+		name      string
+		tail      bool
+		n         int
+		wantFirst memlog.Offset
+		wantCount int
+	}{
+		{name: "oldest 3 records", tail: false, n: 3, wantFirst: 10, wantCount: 3},
+		{name: "latest 3 records", tail: true, n: 3, wantFirst: 22, wantCount: 3},
+		{name: "head n greater than retained records", tail: false, n: 30, wantFirst: 10, wantCount: 15},
+		{name: "tail n greater than retained records", tail: true, n: 30, wantFirst: 10, wantCount: 15},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			read := l.Head
+			if tc.tail {
+				read = l.Tail
+			}
+
+			records, err := read(ctx, tc.n)
+			assert.NilError(t, err)
+			assert.Equal(t, len(records), tc.wantCount)
+
+			for i, r := range records {
+				assert.Equal(t, r.Metadata.Offset, tc.wantFirst+memlog.Offset(i))
+			}
+		})
+	}
+
+	t.Run("skips unwritten reserved offsets", func(t *testing.T) {
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("first"))
+		assert.NilError(t, err)
+		_, err = l.Reserve(ctx, 1)
+		assert.NilError(t, err)
+		_, err = l.Write(ctx, []byte("last"))
+		assert.NilError(t, err)
+
+		records, err := l.Head(ctx, 2)
+		assert.NilError(t, err)
+		assert.Equal(t, len(records), 2)
+		assert.Equal(t, records[1].Metadata.Offset, memlog.Offset(2))
+
+		records, err = l.Tail(ctx, 2)
+		assert.NilError(t, err)
+		assert.Equal(t, len(records), 1)
+		assert.Equal(t, records[0].Metadata.Offset, memlog.Offset(2))
+	})
+}
+
+func TestLog_Digest(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewMock()
+
+	newLog := func(records int) *memlog.Log {
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		for _, d := range memlog.NewTestDataSlice(t, records) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+		return l
+	}
+
+	empty1, err := newLog(0).Digest(ctx)
+	assert.NilError(t, err)
+	empty2, err := newLog(0).Digest(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, empty1, empty2)
+
+	// offsets [0-9] purged
+	replica1, err := newLog(25).Digest(ctx)
+	assert.NilError(t, err)
+	replica2, err := newLog(25).Digest(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, replica1, replica2)
+	assert.Assert(t, !bytes.Equal(replica1, empty1))
+
+	diverged, err := newLog(24).Digest(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, !bytes.Equal(replica1, diverged))
+
+	// different creation time
+	c.Add(time.Second)
+	later, err := newLog(25).Digest(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, !bytes.Equal(replica1, later))
+
+	t.Run("covers only readable records", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+
+		expiring, err := memlog.New(ctx, memlog.WithClock(c))
+		assert.NilError(t, err)
+		_, err = expiring.Write(ctx, []byte("a"))
+		assert.NilError(t, err)
+		_, err = expiring.WriteTTL(ctx, time.Minute, []byte("b"))
+		assert.NilError(t, err)
+		_, err = expiring.Write(ctx, []byte("c"))
+		assert.NilError(t, err)
+
+		reserving, err := memlog.New(ctx, memlog.WithClock(c))
+		assert.NilError(t, err)
+		_, err = reserving.Write(ctx, []byte("a"))
+		assert.NilError(t, err)
+		_, err = reserving.Reserve(ctx, 1)
+		assert.NilError(t, err)
+		_, err = reserving.Write(ctx, []byte("c"))
+		assert.NilError(t, err)
+
+		before, err := expiring.Digest(ctx)
+		assert.NilError(t, err)
+		reserved, err := reserving.Digest(ctx)
+		assert.NilError(t, err)
+		assert.Assert(t, !bytes.Equal(before, reserved))
+
+		c.Add(time.Minute)
+		expired, err := expiring.Digest(ctx)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expired, reserved)
+	})
+}
+
+func TestLog_ForwardTo(t *testing.T) {
+	ctx := context.Background()
+
+	hot, err := memlog.New(ctx, memlog.WithMaxSegmentSize(50))
+	assert.NilError(t, err)
+
+	cold, err := memlog.New(ctx, memlog.WithMaxSegmentSize(1000))
+	assert.NilError(t, err)
+
+	t.Run("fails with nil destination", func(t *testing.T) {
+		_, err = hot.ForwardTo(ctx, nil, 0)
+		assert.ErrorContains(t, err, "must not be nil")
+	})
+
+	t.Run("nothing to forward", func(t *testing.T) {
+		last, err := hot.ForwardTo(ctx, cold, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, last, memlog.InvalidOffset)
+	})
+
+	data := memlog.NewTestDataSlice(t, 170)
+	for _, d := range data[:90] {
+		_, err = hot.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	t.Run("forwards all records", func(t *testing.T) {
+		last, err := hot.ForwardTo(ctx, cold, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, last, memlog.Offset(89))
+	})
+
+	// offsets [0-99] purged in hot log
+	for _, d := range data[90:] {
+		_, err = hot.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	t.Run("fails on purged offset", func(t *testing.T) {
+		last, err := hot.ForwardTo(ctx, cold, 90)
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+		assert.Equal(t, last, memlog.InvalidOffset)
+	})
+
+	t.Run("resumes from checkpoint", func(t *testing.T) {
+		last, err := hot.ForwardTo(ctx, cold, 100)
+		assert.NilError(t, err)
+		assert.Equal(t, last, memlog.Offset(169))
+
+		// gap of purged offsets [90-99]
+		earliest, latest := cold.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(0))
+		assert.Equal(t, latest, memlog.Offset(159))
+
+		r, err := cold.Read(ctx, 90)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, data[100])
+	})
+}
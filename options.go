@@ -1,7 +1,11 @@
 package memlog
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/benbjohnson/clock"
 )
@@ -13,6 +17,13 @@ const (
 	DefaultSegmentSize = 1024
 	// DefaultMaxRecordDataBytes is the maximum data (payload) size of a record
 	DefaultMaxRecordDataBytes = 1024 << 10 // 1MiB
+	// DefaultMaxPreallocBytes is the maximum estimated memory preallocated for
+	// the log segments
+	DefaultMaxPreallocBytes = 1024 << 20 // 1GiB
+	// DefaultPurgeBatch is the number of history segments purged at once
+	DefaultPurgeBatch = 1
+	// DefaultMaxHistorySegments is the number of retained history segments
+	DefaultMaxHistorySegments = 1
 )
 
 // Option customizes a log
@@ -23,6 +34,10 @@ var defaultOptions = []Option{
 	WithStartOffset(DefaultStartOffset),
 	WithMaxSegmentSize(DefaultSegmentSize),
 	WithMaxRecordDataSize(DefaultMaxRecordDataBytes),
+	WithMaxPreallocBytes(DefaultMaxPreallocBytes),
+	WithExtendFailurePolicy(ExtendFailurePanic),
+	WithPurgeBatch(DefaultPurgeBatch),
+	WithMaxHistorySegments(DefaultMaxHistorySegments),
 }
 
 // WithClock uses the specified clock for setting record timestamps
@@ -37,6 +52,381 @@ func WithClock(c clock.Clock) Option {
 	}
 }
 
+// WithChecksumVerification verifies the checksum of every record read from the
+// log, e.g. to detect corruption of records in custom segment stores. Reads of
+// a record whose data does not match its checksum return ErrChecksumMismatch.
+// Checksums are always computed on writes.
+func WithChecksumVerification() Option {
+	return func(log *Log) error {
+		log.conf.verifyChecksums = true
+		return nil
+	}
+}
+
+// WithChunking splits the data of writes larger than the maximum record size
+// (see WithMaxRecordDataSize()) into chunks, which are written atomically as
+// records with contiguous offsets instead of rejecting the write with
+// ErrRecordTooLarge. The offset of the first chunk is returned. Reads and
+// streams of the first chunk return the reassembled record, reads of the other
+// chunks return ErrChunkContinuation and are skipped by batch reads and
+// streams. The number of chunks must not be greater than the segment size.
+// Chunking is not supported with WithDedupeKeyFunc().
+func WithChunking() Option {
+	return func(log *Log) error {
+		log.conf.chunking = true
+		return nil
+	}
+}
+
+// WithCompaction enables key-based compaction of the log. Whenever a segment is
+// sealed, all records in history segments written with WriteKey are removed if
+// a newer record with the same key was written, i.e. only the latest record of
+// each key is retained. Offsets of removed records remain valid but reads
+// return ErrCompacted. Batch reads and streams skip removed records. Records
+// written without a key are never removed by compaction. Compaction requires
+// the default segment store.
+func WithCompaction() Option {
+	return func(log *Log) error {
+		log.compactor = newCompactor()
+		log.conf.compaction = true
+		return nil
+	}
+}
+
+const (
+	// FaultOpRead is passed to a FaultInjector on record reads
+	FaultOpRead = "read"
+	// FaultOpWrite is passed to a FaultInjector on record writes
+	FaultOpWrite = "write"
+)
+
+// FaultInjector is consulted on every read and write operation with the
+// operation (FaultOpRead or FaultOpWrite) and the record offset. For writes,
+// offset is the offset the record would be written to. Returning a non-nil
+// error fails the operation with this error.
+type FaultInjector func(op string, offset Offset) error
+
+// ExtendFailurePolicy defines the behavior of a write when the log fails to
+// create a new active segment, which indicates an abnormal program state
+type ExtendFailurePolicy struct {
+	panic   bool
+	retries int
+}
+
+var (
+	// ExtendFailurePanic panics on extend failures (default)
+	ExtendFailurePanic = ExtendFailurePolicy{panic: true}
+	// ExtendFailureError returns ErrExtendFailed from the failed write
+	ExtendFailureError = ExtendFailurePolicy{}
+)
+
+// ExtendFailureRetry retries extending the log up to n times before returning
+// ErrExtendFailed from the failed write
+func ExtendFailureRetry(n int) ExtendFailurePolicy {
+	return ExtendFailurePolicy{retries: n}
+}
+
+// WithDefaultTimeout bounds blocking operations, e.g. WaitEmpty, to the
+// specified timeout if the provided context has no deadline, so that they do
+// not block indefinitely. Operations return context.DeadlineExceeded when the
+// timeout fires. Contexts with a deadline are not modified. Must be greater
+// than 0. By default blocking operations are not bounded.
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(log *Log) error {
+		if d <= 0 {
+			return errors.New("timeout must be greater than 0")
+		}
+		log.conf.defaultTimeout = d
+		return nil
+	}
+}
+
+// WithDeadLetter retains the data of up to capacity of the most recent writes
+// rejected by a WriteInterceptor or because the data was too large, see
+// Log.DeadLetters(). When capacity is reached, the oldest rejected write is
+// dropped. Note that the memory used by the dead letters is bounded by capacity
+// and the size of the rejected data. Must be greater than 0.
+func WithDeadLetter(capacity int) Option {
+	return func(log *Log) error {
+		if capacity <= 0 {
+			return errors.New("capacity must be greater than 0")
+		}
+		log.dead = newDeadLetters(capacity)
+		return nil
+	}
+}
+
+// WithDedupeKeyFunc enables deduplication of writes using the specified
+// function to compute the idempotency key of the record data, e.g. an ID field
+// of the payload. A write with the same key as a record retained in the log is
+// not written and the offset of the existing record is returned instead. Keys
+// are forgotten when their records are purged from the log.
+func WithDedupeKeyFunc(fn DedupeKeyFunc) Option {
+	return func(log *Log) error {
+		if fn == nil {
+			return errors.New("dedupe key function must not be nil")
+		}
+
+		log.dedupe = newDeduper(fn)
+		log.conf.dedupe = true
+		return nil
+	}
+}
+
+// WithDedupeWindow limits deduplication (see WithDedupeKeyFunc() and
+// WriteWithID()) to records written within the specified duration based on the
+// clock of the log. A write with the same key as an older record is written as
+// a new record. By default, keys are deduplicated until their records are
+// purged. Must be greater than 0.
+func WithDedupeWindow(d time.Duration) Option {
+	return func(log *Log) error {
+		if d <= 0 {
+			return errors.New("dedupe window must be greater than 0")
+		}
+
+		log.conf.dedupeWindow = d
+		return nil
+	}
+}
+
+// WithEncryption encrypts the data of all records in the log with AES-GCM using
+// the specified key, e.g. to not retain sensitive data in plaintext in memory
+// or the write-ahead log (see WithPersistence()). The data is only decrypted
+// when records are read, streamed or passed to a purge handler. Record headers
+// are not encrypted. The caller-defined id of the key is stored in the record
+// header to select the key for decryption and must not be derived from the
+// key. See Log.RotateEncryptionKey() to change the key. If specified multiple
+// times, records encrypted with any of the keys can be read and new records are
+// encrypted with the last key, e.g. to replay a write-ahead log written before
+// a key rotation. id must not be empty and must be unique among the keys. key
+// must be a valid AES-128, AES-192 or AES-256 key, i.e. 16, 24 or 32 bytes.
+// Encryption is not supported with WithDedupeKeyFunc().
+func WithEncryption(id string, key []byte) Option {
+	return func(log *Log) error {
+		if log.keys == nil {
+			log.keys = newKeyring()
+			log.conf.encryption = true
+		}
+
+		if err := log.keys.add(id, key); err != nil {
+			return fmt.Errorf("invalid encryption key: %v", err)
+		}
+		return nil
+	}
+}
+
+// WithEOFSemantics returns io.EOF instead of ErrFutureOffset from Read,
+// ReadBatch and ReadUntilTime at the end of the log for interoperability with
+// io-style read loops. io.EOF is returned unwrapped and can be compared with ==.
+func WithEOFSemantics() Option {
+	return func(log *Log) error {
+		log.conf.eof = true
+		return nil
+	}
+}
+
+// WithExtendFailurePolicy sets the behavior of writes when the log fails to
+// create a new active segment. By default, the log panics.
+func WithExtendFailurePolicy(p ExtendFailurePolicy) Option {
+	return func(log *Log) error {
+		if p.retries < 0 {
+			return errors.New("retries must not be negative")
+		}
+		log.conf.extendPolicy = p
+		return nil
+	}
+}
+
+// WithFaultInjector uses the specified FaultInjector to fail read and write
+// operations on demand, e.g. to return ErrOutOfRange without purging the log.
+//
+// For testing only, do not use in production code.
+func WithFaultInjector(f FaultInjector) Option {
+	return func(log *Log) error {
+		if f == nil {
+			return errors.New("fault injector must not be nil")
+		}
+
+		log.fault = f
+		return nil
+	}
+}
+
+// WriteInterceptor is invoked on every write with the context of the write and
+// the record data. The offset the record will be written at is available with
+// WriteOffset(ctx). A non-nil returned data replaces the record data, which is
+// then subject to the maximum record size check. Returning ErrDropped drops the
+// record without failing the write. Any other non-nil error fails the write
+// with this error.
+type WriteInterceptor func(ctx context.Context, data []byte) ([]byte, error)
+
+// writeOffsetKey is the context key of the offset passed to a WriteInterceptor
+type writeOffsetKey struct{}
+
+// WriteOffset returns the offset the record passed to a WriteInterceptor with
+// ctx will be written at. If ctx is not the context of a WriteInterceptor,
+// false is returned.
+func WriteOffset(ctx context.Context) (Offset, bool) {
+	offset, ok := ctx.Value(writeOffsetKey{}).(Offset)
+	return offset, ok
+}
+
+// WithPersistence writes every record to an append-only write-ahead log file in
+// the specified directory, which is created if it does not exist, so that the
+// log survives process restarts. New replays the write-ahead log, restoring
+// the offsets, segments and metadata of the records, including purges. Reads
+// are served from memory.
+//
+// Records are written to the file before they are added to the log, but the
+// file is not synced, i.e. records might be lost if the operating system
+// crashes. An incomplete record at the end of the file, e.g. from a crash
+// during a write, is discarded on replay. The file grows with every write and
+// is not rewritten when records are purged or truncated, i.e. it contains all
+// records ever written to the log until it is compacted with Log.CompactWAL().
+// The start offset of the log must not be changed between restarts.
+// Reservations (see Log.Reserve()) are not supported. Use Log.Close() to close
+// the file.
+func WithPersistence(dir string) Option {
+	return func(log *Log) error {
+		if dir == "" {
+			return errors.New("directory must not be empty")
+		}
+
+		log.conf.persistDir = dir
+		return nil
+	}
+}
+
+// WithRetention purges records older than d based on the clock of the log,
+// regardless of the fill level of the segments, e.g. to retain the events of the
+// last 24h. Retention is enforced per segment: a segment is purged once its
+// latest record is older than d, i.e. a segment might retain records older than
+// d until all its records are expired. Retention is enforced on writes and with
+// Log.Expire(). Requires record timestamps to be non-decreasing, see
+// WithMonotonicTimestamps(). Must be greater than 0.
+func WithRetention(d time.Duration) Option {
+	return func(log *Log) error {
+		if d <= 0 {
+			return errors.New("retention must be greater than 0")
+		}
+
+		log.conf.retention = d
+		return nil
+	}
+}
+
+// WithSegmentStore creates the store of each segment with newStore, e.g. to back
+// segments with storage other than memory. newStore is called with the segment
+// size when the log is created and every time the log is extended with a new
+// active segment. If newStore returns nil or a store with insufficient
+// capacity, New or the write extending the log fails (see
+// WithExtendFailurePolicy()). Stores are closed when their segment is purged.
+//
+// Snapshots and read replicas copy the records of custom stores into memory.
+// Reservations (see Log.Reserve()) and Log.TrimMemory() are not supported with
+// custom stores. By default, records are stored in memory.
+func WithSegmentStore(newStore func(size int) SegmentStore) Option {
+	return func(log *Log) error {
+		if newStore == nil {
+			return errors.New("segment store function must not be nil")
+		}
+
+		log.newStore = newStore
+		log.conf.customStore = true
+		return nil
+	}
+}
+
+// WithWriteInterceptor uses the specified WriteInterceptor on every write, e.g.
+// for validation, enrichment, sampling or redacting records. The interceptor is
+// called while holding the log write lock and must not call any methods on the
+// log. Use Log.TryWrite() to detect dropped records.
+//
+// If specified multiple times, the interceptors are chained in the order they
+// are specified: each interceptor receives the data returned by the previous
+// one. The context of the write is passed through the chain. If an interceptor
+// drops the record or returns an error, the remaining interceptors are not
+// called.
+func WithWriteInterceptor(i WriteInterceptor) Option {
+	return func(log *Log) error {
+		if i == nil {
+			return errors.New("write interceptor must not be nil")
+		}
+
+		if log.intercept != nil {
+			i = chainInterceptors(log.intercept, i)
+		}
+
+		log.intercept = i
+		return nil
+	}
+}
+
+// chainInterceptors returns a WriteInterceptor calling first and then second
+// with the data returned by first
+func chainInterceptors(first, second WriteInterceptor) WriteInterceptor {
+	return func(ctx context.Context, data []byte) ([]byte, error) {
+		newData, err := first(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if newData != nil {
+			data = newData
+		}
+
+		secondData, err := second(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if secondData != nil {
+			return secondData, nil
+		}
+		return newData, nil
+	}
+}
+
+// ReadInterceptor is invoked on every read with a copy of the record, which can
+// be modified except for its offset. The returned record is returned to the
+// reader instead. A non-nil error fails the read with this error. Returning
+// ErrFiltered hides the record: batch reads and streams skip it.
+type ReadInterceptor func(ctx context.Context, r Record) (Record, error)
+
+// WithReadInterceptor uses the specified ReadInterceptor on reads of single
+// records and batches, i.e. Read, ReadBatch, ReadRangeBytes and the other read
+// methods, streams and cursors, e.g. for decompression. The interceptor is not
+// applied to Snapshot, WriteSnapshot, ReadReplica and Digest, which return the
+// stored records, i.e. it is not suitable to restrict access to records. The
+// interceptor is called while holding the log read lock and must not call any
+// methods on the log.
+//
+// If specified multiple times, the interceptors are chained in the order they
+// are specified: each interceptor receives the record returned by the previous
+// one. If an interceptor returns an error, the remaining interceptors are not
+// called.
+func WithReadInterceptor(i ReadInterceptor) Option {
+	return func(log *Log) error {
+		if i == nil {
+			return errors.New("read interceptor must not be nil")
+		}
+
+		if prev := log.readIntercept; prev != nil {
+			next := i
+			i = func(ctx context.Context, r Record) (Record, error) {
+				r, err := prev(ctx, r)
+				if err != nil {
+					return Record{}, err
+				}
+				return next(ctx, r)
+			}
+		}
+
+		log.readIntercept = i
+		return nil
+	}
+}
+
 // WithMaxRecordDataSize sets the maximum record data (payload) size in bytes
 func WithMaxRecordDataSize(size int) Option {
 	return func(log *Log) error {
@@ -48,6 +438,60 @@ func WithMaxRecordDataSize(size int) Option {
 	}
 }
 
+// WithFirstWriteHook calls fn exactly once with the offset of the first
+// successfully written record, e.g. to lazily start consumers once the log has
+// data. fn is called while holding the log write lock and must not call any
+// methods on the log, e.g. start a goroutine instead.
+func WithFirstWriteHook(fn func(offset Offset)) Option {
+	return func(log *Log) error {
+		if fn == nil {
+			return errors.New("first write hook must not be nil")
+		}
+		log.firstHook = fn
+		return nil
+	}
+}
+
+// WithGlobalSequence stamps each record with a monotonically increasing
+// sequence number (Header.Seq) starting at start. Contrary to offsets, the
+// sequence is independent of the log configuration, e.g. to correlate records
+// across logs.
+func WithGlobalSequence(start uint64) Option {
+	return func(log *Log) error {
+		log.conf.sequence = true
+		log.seq = start
+		return nil
+	}
+}
+
+// WithMaxPreallocBytes sets the maximum estimated memory in bytes which is
+// allocated for the active and history segments. New returns an error if the
+// maximum number of segments (active and history) multiplied by the segment
+// size and the size of a Record exceeds this limit. Must be greater than 0.
+func WithMaxPreallocBytes(n int) Option {
+	return func(log *Log) error {
+		if n <= 0 {
+			return errors.New("size must be greater than 0")
+		}
+		log.conf.maxPreallocSize = n
+		return nil
+	}
+}
+
+// WithMaxReadBatch sets the maximum number of records read in one batch read
+// operation, e.g. ReadBatch, regardless of the size of the provided batch. This
+// bounds the time the read lock is held under mixed read/write load. Must be
+// greater than 0. By default batch reads are not limited.
+func WithMaxReadBatch(n int) Option {
+	return func(log *Log) error {
+		if n <= 0 {
+			return errors.New("size must be greater than 0")
+		}
+		log.conf.maxReadBatch = n
+		return nil
+	}
+}
+
 // WithMaxSegmentSize sets the maximum size, i.e. number of offsets, in a log
 // segment. Must be greater than 0.
 func WithMaxSegmentSize(size int) Option {
@@ -60,6 +504,106 @@ func WithMaxSegmentSize(size int) Option {
 	}
 }
 
+// WithMonotonicTimestamps guarantees that record creation timestamps never
+// regress, even if the clock goes backwards, e.g. due to NTP adjustments. If
+// the clock returns a time before the creation time of the previous record,
+// the new record is stamped with the creation time of the previous record.
+func WithMonotonicTimestamps() Option {
+	return func(log *Log) error {
+		log.conf.monotonic = true
+		return nil
+	}
+}
+
+// WithPurgeHandler calls fn with the records of each history segment right
+// before the segment is purged, e.g. to archive purged records to disk or
+// object storage instead of losing them. ctx is the context of the operation
+// causing the purge, e.g. Log.Write(). Unwritten reserved offsets (see
+// Log.Reserve()) are passed as records without data. Segments purged while the
+// log is restored from the write-ahead log (see WithPersistence()) are not
+// passed to fn, as they might have been passed before the restart. fn is called
+// while holding the log write lock and must not call any methods on the log or
+// modify the records.
+func WithPurgeHandler(fn func(ctx context.Context, seg []Record)) Option {
+	return func(log *Log) error {
+		if fn == nil {
+			return errors.New("purge handler must not be nil")
+		}
+
+		log.purgeFn = fn
+		return nil
+	}
+}
+
+// WithRateTracker enables tracking of the write throughput over a sliding
+// window of the specified length, see Log.WriteRate(). The window is divided
+// into 10 buckets, i.e. the rate is updated in window/10 increments. Must be
+// at least 10ns.
+func WithRateTracker(window time.Duration) Option {
+	return func(log *Log) error {
+		if window < rateBuckets {
+			return errors.New("window must be at least 10ns")
+		}
+		log.rate = newRateTracker(window)
+		log.conf.rateTracker = true
+		return nil
+	}
+}
+
+// WithName sets a human-readable name of the log, e.g. to distinguish many
+// logs in a process in error messages and metrics. See Log.Name().
+func WithName(name string) Option {
+	return func(log *Log) error {
+		log.conf.name = name
+		return nil
+	}
+}
+
+// WithPurgeBatch defers purging the history until n history segments have
+// accumulated, which are then purged at once. This reduces the purge frequency
+// with small segment sizes, but increases the retention of the log by up to n-1
+// segments. Must be greater than 0.
+func WithPurgeBatch(n int) Option {
+	return func(log *Log) error {
+		if n <= 0 {
+			return errors.New("purge batch must be greater than 0")
+		}
+		log.conf.purgeBatch = n
+		return nil
+	}
+}
+
+// WithMaxHistorySegments retains up to n sealed history segments. When the
+// active segment is full and n history segments are retained, the oldest
+// history segment is purged, i.e. the log retains between n and n+1 segments of
+// records. If combined with WithPurgeBatch(), the larger value determines the
+// number of retained history segments and the purge batch the number of
+// segments purged at once. Must be greater than 0.
+func WithMaxHistorySegments(n int) Option {
+	return func(log *Log) error {
+		if n <= 0 {
+			return errors.New("history segments must be greater than 0")
+		}
+		log.conf.maxHistory = n
+		return nil
+	}
+}
+
+// WithReadYield releases and re-acquires the read lock every k records during
+// long read operations, e.g. ReadBatch, so that writers do not starve during
+// big scans. Offsets are re-validated after re-acquiring the lock, i.e. records
+// purged in the meantime cause ErrOutOfRange. Must be greater than 0. By
+// default long reads hold the lock for the whole operation.
+func WithReadYield(k int) Option {
+	return func(log *Log) error {
+		if k <= 0 {
+			return errors.New("yield interval must be greater than 0")
+		}
+		log.conf.readYield = k
+		return nil
+	}
+}
+
 // WithStartOffset sets the start offset of the log. Must be equal or greater
 // than 0.
 func WithStartOffset(offset Offset) Option {
@@ -71,3 +615,85 @@ func WithStartOffset(offset Offset) Option {
 		return nil
 	}
 }
+
+// WithStrictTimeOrdering rejects writes with Log.WriteAt() with
+// ErrNonMonotonicTime if the specified creation time is before the creation
+// time of the previous record, e.g. to detect data errors during replay. Equal
+// creation times are accepted. Contrary to WithMonotonicTimestamps(), which
+// clamps such timestamps, the record is not written.
+func WithStrictTimeOrdering() Option {
+	return func(log *Log) error {
+		log.conf.strictTime = true
+		return nil
+	}
+}
+
+// WithUserValue associates value with key on the log, e.g. to keep a reference
+// to a parent service handle with the log, which can be retrieved with
+// Log.Value(). Like context.WithValue(), key must not be nil and must be
+// comparable, and should be of a user-defined type to avoid collisions. If the
+// option is specified multiple times for the same key, the last value wins.
+func WithUserValue(key, value interface{}) Option {
+	return func(log *Log) error {
+		if key == nil {
+			return errors.New("key must not be nil")
+		}
+
+		if !reflect.TypeOf(key).Comparable() {
+			return errors.New("key must be comparable")
+		}
+
+		if log.values == nil {
+			log.values = make(map[interface{}]interface{})
+		}
+		log.values[key] = value
+		return nil
+	}
+}
+
+// WithoutTimestamps does not stamp records with the time of the log clock on
+// write, i.e. Header.Created is zero, to avoid the overhead of reading the clock
+// for maximum write throughput. Records written with Log.WriteAt() keep the
+// specified creation time.
+//
+// Time-based features are not available: Log.OffsetForTime() and Log.Age()
+// return an error and New returns an error if combined with WithTimeIndex() or
+// WithRateTracker().
+func WithoutTimestamps() Option {
+	return func(log *Log) error {
+		log.conf.noTimestamps = true
+		return nil
+	}
+}
+
+// WithStreamRateLimit limits the delivery rate of each Stream created from the
+// log to the specified number of records per second, e.g. to not overwhelm a
+// downstream consumer when replaying a large backlog. Stream.Next() waits using
+// the clock of the log. Must not be negative. By default (0), delivery is not
+// limited.
+func WithStreamRateLimit(recordsPerSecond int) Option {
+	return func(log *Log) error {
+		if recordsPerSecond < 0 {
+			return errors.New("rate limit must not be negative")
+		}
+		log.conf.streamRate = recordsPerSecond
+		return nil
+	}
+}
+
+// WithTimeIndex maintains a sparse index of the first offset written in each
+// time bucket of the specified granularity to speed up Log.OffsetForTime() for
+// time-heavy query workloads. The index is pruned when records are purged. The
+// memory cost is one map entry per time bucket with retained records, i.e. a
+// small granularity with a long retention can require a large index. Must be
+// greater than 0.
+func WithTimeIndex(granularity time.Duration) Option {
+	return func(log *Log) error {
+		if granularity <= 0 {
+			return errors.New("granularity must be greater than 0")
+		}
+		log.timeIdx = newTimeIndex(granularity)
+		log.conf.timeIndex = true
+		return nil
+	}
+}
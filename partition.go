@@ -0,0 +1,147 @@
+package memlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// PartitionedLog is a log split into independently locked partitions to reduce
+// write contention. Writes are distributed round-robin across partitions.
+// Contrary to the sharded package, reads are not keyed but merged across all
+// partitions.
+//
+// The offset space is split across partitions: partition p owns the offsets
+// start+p, start+p+n, start+p+2n, etc. where n is the number of partitions.
+// Thus offsets are unique but, due to concurrent writes, not necessarily
+// ordered by creation time.
+//
+// Safe for concurrent use.
+type PartitionedLog struct {
+	next       uint64 // round-robin write counter, first field for 64-bit alignment
+	start      Offset
+	partitions []*Log
+}
+
+// Partition creates a new empty partitioned log with n partitions. Each
+// partition uses the clock, segment, record and preallocation size settings of
+// l. Fault injection and write interceptors are not inherited. l is not
+// modified.
+func (l *Log) Partition(n int) (*PartitionedLog, error) {
+	if n <= 0 {
+		return nil, errors.New("number of partitions must be greater than 0")
+	}
+
+	opts := []Option{
+		WithClock(l.clock),
+		WithMaxSegmentSize(l.conf.segmentSize),
+		WithMaxRecordDataSize(l.conf.maxRecordSize),
+		WithMaxPreallocBytes(l.conf.maxPreallocSize),
+	}
+
+	pl := PartitionedLog{
+		start:      l.conf.startOffset,
+		partitions: make([]*Log, n),
+	}
+
+	for i := 0; i < n; i++ {
+		p, err := New(context.Background(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("create partition: %w", err)
+		}
+		pl.partitions[i] = p
+	}
+
+	return &pl, nil
+}
+
+// Write creates a new record in the next partition (round-robin) with the
+// provided data. The write offset of the new record is returned. If an error
+// occurs, an invalid offset (InvalidOffset) and the error is returned.
+//
+// Safe for concurrent use.
+func (pl *PartitionedLog) Write(ctx context.Context, data []byte) (Offset, error) {
+	n := uint64(len(pl.partitions))
+	p := (atomic.AddUint64(&pl.next, 1) - 1) % n
+
+	local, err := pl.partitions[p].Write(ctx, data)
+	if err != nil {
+		return InvalidOffset, err
+	}
+
+	return pl.globalOffset(int(p), local), nil
+}
+
+// Read reads a record at the specified offset from the partition owning the
+// offset. If an error occurs, an invalid (empty) record and the error is
+// returned.
+//
+// Safe for concurrent use.
+func (pl *PartitionedLog) Read(ctx context.Context, offset Offset) (Record, error) {
+	if offset < pl.start {
+		return Record{}, ErrOutOfRange
+	}
+
+	n := Offset(len(pl.partitions))
+	p := (offset - pl.start) % n
+	local := (offset - pl.start) / n
+
+	r, err := pl.partitions[p].Read(ctx, local)
+	if err != nil {
+		return Record{}, err
+	}
+
+	r.Metadata.Offset = offset
+	return r, nil
+}
+
+// ReadMerged returns all available records across partitions ordered by their
+// creation time. Records with the same creation time are ordered by offset.
+// Each partition is read from a consistent snapshot (see Log.Snapshot()), but
+// partitions are not snapshotted atomically with respect to each other.
+//
+// Safe for concurrent use.
+func (pl *PartitionedLog) ReadMerged(ctx context.Context) ([]Record, error) {
+	var records []Record
+
+	for i, p := range pl.partitions {
+		v, err := p.Snapshot(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot partition %d: %w", i, err)
+		}
+
+		earliest, latest := v.Range(ctx)
+		if earliest == InvalidOffset {
+			continue
+		}
+
+		batch := make([]Record, latest-earliest+1)
+		count, err := v.ReadBatch(ctx, earliest, batch)
+		if err != nil {
+			return nil, fmt.Errorf("read partition %d: %w", i, err)
+		}
+
+		for _, r := range batch[:count] {
+			r.Metadata.Offset = pl.globalOffset(i, r.Metadata.Offset)
+			records = append(records, r)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		ti, tj := records[i].Metadata.Created, records[j].Metadata.Created
+		if ti.Equal(tj) {
+			return records[i].Metadata.Offset < records[j].Metadata.Offset
+		}
+		return ti.Before(tj)
+	})
+
+	return records, nil
+}
+
+// globalOffset converts a partition-local offset into an offset of the
+// partitioned log
+func (pl *PartitionedLog) globalOffset(partition int, local Offset) Offset {
+	return pl.start + local*Offset(len(pl.partitions)) + Offset(partition)
+}
@@ -0,0 +1,98 @@
+package memlog_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestLog_Partition(t *testing.T) {
+	t.Run("fails with invalid number of partitions", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		pl, err := l.Partition(0)
+		assert.ErrorContains(t, err, "must be greater than 0")
+		assert.Assert(t, pl == nil)
+	})
+
+	t.Run("writes round-robin and reads by offset", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithStartOffset(10))
+		assert.NilError(t, err)
+
+		pl, err := l.Partition(3)
+		assert.NilError(t, err)
+
+		for i, d := range memlog.NewTestDataSlice(t, 9) {
+			offset, err := pl.Write(ctx, d)
+			assert.NilError(t, err)
+			assert.Equal(t, offset, memlog.Offset(10+i))
+
+			r, err := pl.Read(ctx, offset)
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Offset, offset)
+			assert.DeepEqual(t, r.Data, d)
+		}
+
+		_, err = pl.Read(ctx, 9)
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+
+		_, err = pl.Read(ctx, 19)
+		assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+	})
+
+	t.Run("merges concurrent writes ordered by time", func(t *testing.T) {
+		const (
+			writers = 4
+			writes  = 50
+		)
+
+		ctx := context.Background()
+		c := clock.NewMock()
+		l, err := memlog.New(ctx, memlog.WithClock(c))
+		assert.NilError(t, err)
+
+		pl, err := l.Partition(writers)
+		assert.NilError(t, err)
+
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < writes; i++ {
+					_, err := pl.Write(ctx, []byte("data"))
+					assert.Check(t, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// later writes are ordered last
+		c.Add(1)
+		last, err := pl.Write(ctx, []byte("last"))
+		assert.NilError(t, err)
+
+		records, err := pl.ReadMerged(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, len(records), writers*writes+1)
+		assert.Equal(t, records[len(records)-1].Metadata.Offset, last)
+
+		seen := make(map[memlog.Offset]struct{})
+		for i, r := range records {
+			seen[r.Metadata.Offset] = struct{}{}
+			if i > 0 {
+				assert.Assert(t, !r.Metadata.Created.Before(records[i-1].Metadata.Created))
+			}
+		}
+		assert.Equal(t, len(seen), len(records))
+	})
+}
@@ -0,0 +1,84 @@
+package memlog
+
+import (
+	"context"
+	"errors"
+)
+
+// PrefetchReader reads records from a log in a background goroutine ahead of
+// the consumer, so that Next rarely blocks on the log when processing records
+// is expensive. Create a PrefetchReader with Log.NewPrefetchReader() and call
+// Close to stop the background goroutine.
+//
+// Safe for concurrent use.
+type PrefetchReader struct {
+	records chan Record
+	cancel  context.CancelFunc
+	stopped chan struct{} // closed when the prefetcher returned
+	err     error         // set before records is closed
+}
+
+// NewPrefetchReader returns a PrefetchReader reading records in order starting
+// at the given start offset, prefetching up to depth records. If the start
+// offset is in the future, the prefetcher continuously polls until this offset
+// is written. If depth is not greater than 0, the reader is stopped and Next
+// returns the error.
+func (l *Log) NewPrefetchReader(ctx context.Context, start Offset, depth int) *PrefetchReader {
+	ctx, cancel := context.WithCancel(ctx)
+	p := PrefetchReader{
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+	}
+
+	if depth <= 0 {
+		p.records = make(chan Record)
+		p.err = errors.New("depth must be greater than 0")
+		close(p.records)
+		close(p.stopped)
+		return &p
+	}
+
+	p.records = make(chan Record, depth)
+	go p.prefetch(ctx, l.Stream(ctx, start))
+
+	return &p
+}
+
+func (p *PrefetchReader) prefetch(ctx context.Context, s Stream) {
+	defer close(p.stopped)
+	defer close(p.records)
+
+	for {
+		r, ok := s.Next()
+		if !ok {
+			p.err = s.Err()
+			return
+		}
+
+		select {
+		case p.records <- r:
+		case <-ctx.Done():
+			p.err = ctx.Err()
+			return
+		}
+	}
+}
+
+// Next blocks until the next record is available. If the prefetcher stopped,
+// e.g. because the next offset was purged (ErrOutOfRange) or the context was
+// cancelled, the remaining prefetched records are returned before an invalid
+// (empty) record and the error is returned.
+func (p *PrefetchReader) Next() (Record, error) {
+	r, ok := <-p.records
+	if !ok {
+		return Record{}, p.err
+	}
+	return r, nil
+}
+
+// Close stops the prefetcher and waits until it returned. Subsequent calls to
+// Next return the remaining prefetched records followed by an error.
+func (p *PrefetchReader) Close() {
+	p.cancel()
+	<-p.stopped
+}
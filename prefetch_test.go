@@ -0,0 +1,92 @@
+package memlog_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestLog_NewPrefetchReader(t *testing.T) {
+	t.Run("fails with invalid depth", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		p := l.NewPrefetchReader(ctx, 0, 0)
+		defer p.Close()
+
+		_, err = p.Next()
+		assert.ErrorContains(t, err, "must be greater than 0")
+	})
+
+	t.Run("reads records in order then stops on close", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		data := memlog.NewTestDataSlice(t, 50)
+		for _, d := range data[:25] {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		p := l.NewPrefetchReader(ctx, 0, 10)
+		for i := 0; i < 25; i++ {
+			r, err := p.Next()
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Offset, memlog.Offset(i))
+			assert.DeepEqual(t, r.Data, data[i])
+		}
+
+		// records written after the prefetcher reached the end of the log
+		for _, d := range data[25:] {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		for i := 25; i < 50; i++ {
+			r, err := p.Next()
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Offset, memlog.Offset(i))
+		}
+
+		p.Close()
+		_, err = p.Next()
+		assert.Assert(t, errors.Is(err, context.Canceled))
+	})
+
+	t.Run("stops on cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		p := l.NewPrefetchReader(ctx, 0, 10)
+		defer p.Close()
+
+		cancel()
+		_, err = p.Next()
+		assert.Assert(t, errors.Is(err, context.Canceled))
+	})
+
+	t.Run("surfaces purged offsets", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		// purges offsets [0-9]
+		for _, d := range memlog.NewTestDataSlice(t, 21) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		p := l.NewPrefetchReader(ctx, 0, 10)
+		defer p.Close()
+
+		_, err = p.Next()
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+	})
+}
@@ -0,0 +1,54 @@
+package memlog
+
+import (
+	"time"
+)
+
+const rateBuckets = 10
+
+// rateTracker counts events in a sliding window divided into fixed-size time
+// buckets. Not safe for concurrent use.
+type rateTracker struct {
+	window time.Duration
+	bucket time.Duration
+	slots  [rateBuckets]int64 // time slot of each bucket
+	counts [rateBuckets]uint64
+}
+
+func newRateTracker(window time.Duration) *rateTracker {
+	rt := rateTracker{
+		window: window,
+		bucket: window / rateBuckets,
+	}
+
+	for i := range rt.slots {
+		rt.slots[i] = -1
+	}
+
+	return &rt
+}
+
+// add records one event at the given time
+func (rt *rateTracker) add(now time.Time) {
+	slot := now.UnixNano() / int64(rt.bucket)
+	i := slot % rateBuckets
+	if rt.slots[i] != slot {
+		rt.slots[i] = slot
+		rt.counts[i] = 0
+	}
+	rt.counts[i]++
+}
+
+// rate returns the events per second in the window ending at the given time
+func (rt *rateTracker) rate(now time.Time) float64 {
+	current := now.UnixNano() / int64(rt.bucket)
+
+	var total uint64
+	for i, slot := range rt.slots {
+		if slot > current-rateBuckets && slot <= current {
+			total += rt.counts[i]
+		}
+	}
+
+	return float64(total) / rt.window.Seconds()
+}
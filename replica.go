@@ -0,0 +1,89 @@
+package memlog
+
+import (
+	"context"
+)
+
+// ReadReplica returns a read-only copy of the log pinned to the records
+// available at the time of the call, e.g. for read-heavy analytics which
+// should not contend with writers for the lock of the log. Writes and purges in
+// the log are not visible in the replica. Writes to the replica return
+// ErrReadOnly.
+//
+// The replica shares the records of sealed history segments with the log
+// without copying them, as they are never modified. Only the active segment and
+// segments with unwritten reserved offsets (see Reserve) are copied. If the log
+// was created with WithSegmentStore(), all records are copied into memory. Hooks,
+// interceptors and deduplication of the log are not applied to the replica.
+// Streams from the replica never observe new records and must be stopped with
+// their context or Log.Close().
+//
+// Safe for concurrent use.
+func (l *Log) ReadReplica(ctx context.Context) (*Log, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	r := Log{
+		conf:      l.conf,
+		offset:    l.offset,
+		floor:     l.floor,
+		purged:    l.purged,
+		purges:    l.purges,
+		created:   l.created,
+		destroyed: l.destroyed,
+		bytes:     l.bytes,
+		records:   l.records,
+		truncated: l.truncated,
+		writes:    l.writes,
+		seq:       l.seq,
+		clock:     l.clock,
+		lastWrite: l.lastWrite,
+		values:    l.values,
+		keys:      l.keys,
+		readOnly:  true,
+	}
+
+	for _, h := range l.history {
+		s, err := l.viewSegment(h)
+		if err != nil {
+			return nil, err
+		}
+		r.history = append(r.history, s)
+	}
+
+	active, err := l.active.records()
+	if err != nil {
+		return nil, err
+	}
+
+	r.active = &segment{
+		start: l.active.start,
+		size:  l.active.size,
+		bytes: l.active.bytes,
+		count: l.active.count,
+		store: &sliceStore{
+			size:    l.active.size,
+			records: append([]Record(nil), active...),
+		},
+	}
+
+	if len(l.reserved) > 0 {
+		r.reserved = make(map[Offset]struct{}, len(l.reserved))
+		for offset := range l.reserved {
+			r.reserved[offset] = struct{}{}
+		}
+	}
+
+	if l.timeIdx != nil {
+		r.timeIdx = newTimeIndex(l.timeIdx.granularity)
+		for b, offset := range l.timeIdx.buckets {
+			r.timeIdx.buckets[b] = offset
+		}
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,94 @@
+package memlog_test
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestLog_ReadReplica(t *testing.T) {
+	t.Run("fails when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		cancel()
+		r, err := l.ReadReplica(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Assert(t, r == nil)
+	})
+
+	t.Run("replica is pinned to records at creation time", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10), memlog.WithName("orders"))
+		assert.NilError(t, err)
+
+		for _, d := range memlog.NewTestDataSlice(t, 15) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		replica, err := l.ReadReplica(ctx)
+		assert.NilError(t, err)
+		assert.NilError(t, replica.Validate())
+		assert.Equal(t, replica.Name(), "orders")
+
+		// purges offsets [0-9] in the log
+		for _, d := range memlog.NewTestDataSlice(t, 10) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		earliest, latest := replica.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(0))
+		assert.Equal(t, latest, memlog.Offset(14))
+
+		for offset := earliest; offset <= latest; offset++ {
+			want, err := l.Read(ctx, offset)
+			if offset < 10 {
+				assert.ErrorIs(t, err, memlog.ErrOutOfRange)
+			} else {
+				assert.NilError(t, err)
+			}
+
+			got, err := replica.Read(ctx, offset)
+			assert.NilError(t, err)
+			assert.Equal(t, got.Metadata.Offset, offset)
+			if offset >= 10 {
+				assert.DeepEqual(t, got, want)
+			}
+		}
+
+		_, err = replica.Read(ctx, 15)
+		assert.ErrorIs(t, err, memlog.ErrFutureOffset)
+	})
+
+	t.Run("replica rejects writes", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		start, err := l.Reserve(ctx, 2)
+		assert.NilError(t, err)
+
+		replica, err := l.ReadReplica(ctx)
+		assert.NilError(t, err)
+
+		_, err = replica.Write(ctx, []byte("data"))
+		assert.ErrorIs(t, err, memlog.ErrReadOnly)
+
+		_, err = replica.Reserve(ctx, 1)
+		assert.ErrorIs(t, err, memlog.ErrReadOnly)
+
+		err = replica.WriteReserved(ctx, start, []byte("data"))
+		assert.ErrorIs(t, err, memlog.ErrReadOnly)
+
+		// writing the reservation in the log does not modify the replica
+		assert.NilError(t, l.WriteReserved(ctx, start, []byte("data")))
+		_, err = replica.Read(ctx, start)
+		assert.ErrorIs(t, err, memlog.ErrNoRecord)
+	})
+}
@@ -0,0 +1,159 @@
+package memlog
+
+import (
+	"context"
+	"errors"
+)
+
+// Reserve reserves the next n offsets of the log for records which are written
+// later with WriteReserved, e.g. by producers which compute records out of
+// order but know their order a priori. The first reserved offset is returned.
+// n must be greater than 0 and must not be greater than the segment size of
+// the log.
+//
+// Reads of a reserved offset which has not been written yet return
+// ErrNoRecord, i.e. batch reads stop at unwritten reserved offsets and streams
+// wait until they are written. VerifyContiguous reports unwritten reserved offsets as gaps.
+// Reserved offsets are purged like any other record, regardless of whether
+// they have been written. WriteReserved returns ErrOutOfRange for purged
+// reserved offsets.
+//
+// Reservations are not supported if the log was created with
+// WithSegmentStore() as stores are append-only, or with WithPersistence().
+//
+// If an error occurs, InvalidOffset and the error is returned. Offsets reserved
+// before the error occurred remain reserved.
+//
+// Safe for concurrent use.
+func (l *Log) Reserve(ctx context.Context, n int) (Offset, error) {
+	if n <= 0 || n > l.conf.segmentSize {
+		return InvalidOffset, errors.New("n must be greater than 0 and not greater than the segment size")
+	}
+
+	if l.newStore != nil {
+		return InvalidOffset, errors.New("reservations require the default segment store")
+	}
+
+	if l.conf.persistDir != "" {
+		return InvalidOffset, errors.New("reservations are not supported with persistence")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return InvalidOffset, ctx.Err()
+	}
+
+	if l.closed {
+		return InvalidOffset, ErrClosed
+	}
+
+	if l.readOnly {
+		return InvalidOffset, ErrReadOnly
+	}
+
+	if l.reserved == nil {
+		l.reserved = make(map[Offset]struct{})
+	}
+
+	start := l.offset
+	for i := 0; i < n; i++ {
+		r := Record{
+			Metadata: Header{
+				Offset: l.offset,
+			},
+		}
+
+		if err := l.append(ctx, r); err != nil {
+			return InvalidOffset, err
+		}
+
+		l.reserved[l.offset] = struct{}{}
+		l.offset++
+	}
+
+	l.notify()
+	return start, nil
+}
+
+// WriteReserved writes a record with the provided data at the specified offset
+// reserved with Reserve. The record is created with the current time of the
+// log clock, i.e. record timestamps might not be ordered by offset (see
+// OffsetForTime). WriteInterceptor, duplicate detection and global sequence
+// numbers are not applied. Otherwise the record is accounted like any other
+// write, e.g. in Stats(), the time index and for compaction and deduplication
+// of subsequent writes. If the offset is not reserved or already written,
+// ErrNotReserved is returned.
+//
+// Safe for concurrent use.
+func (l *Log) WriteReserved(ctx context.Context, offset Offset, data []byte) error {
+	if len(data) > l.conf.maxRecordSize {
+		return ErrRecordTooLarge
+	}
+
+	if len(data) == 0 {
+		return errors.New("no data provided")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	if l.readOnly {
+		return ErrReadOnly
+	}
+
+	if offset >= l.offset {
+		return ErrFutureOffset
+	}
+
+	s, err := l.getSegment(offset)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := l.reserved[offset]; !ok {
+		return ErrNotReserved
+	}
+
+	dCopy := make([]byte, len(data))
+	copy(dCopy, data)
+	r := Record{
+		Metadata: Header{
+			Offset:   offset,
+			Created:  l.clock.Now().UTC(),
+			Checksum: checksum(dCopy),
+		},
+		Data: dCopy,
+	}
+
+	r, err = l.encrypt(r)
+	if err != nil {
+		return err
+	}
+
+	s.fill(offset, r)
+	delete(l.reserved, offset)
+	l.track(r)
+	l.notify()
+	return nil
+}
+
+// hasReserved returns true if s contains reserved offsets which have not been
+// written yet. Must be protected with a lock by the caller.
+func (l *Log) hasReserved(s *segment) bool {
+	for offset := range l.reserved {
+		if offset >= s.start && offset <= s.currentOffset() {
+			return true
+		}
+	}
+	return false
+}
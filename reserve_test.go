@@ -0,0 +1,225 @@
+package memlog_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestLog_Reserve(t *testing.T) {
+	t.Run("fails with invalid n", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		_, err = l.Reserve(ctx, 0)
+		assert.ErrorContains(t, err, "must be greater than 0")
+
+		_, err = l.Reserve(ctx, 11)
+		assert.ErrorContains(t, err, "not greater than the segment size")
+	})
+
+	t.Run("reserves and writes offsets out of order", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("first"))
+		assert.NilError(t, err)
+
+		start, err := l.Reserve(ctx, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, start, memlog.Offset(1))
+
+		offset, err := l.Write(ctx, []byte("last"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(11))
+
+		_, err = l.Read(ctx, 5)
+		assert.Assert(t, errors.Is(err, memlog.ErrNoRecord))
+
+		gap, ok, err := l.VerifyContiguous(ctx, 0, 11)
+		assert.NilError(t, err)
+		assert.Assert(t, !ok)
+		assert.Equal(t, gap, memlog.Offset(1))
+
+		v, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+
+		// fill in reverse order, across sealed and active segment
+		data := memlog.NewTestDataSlice(t, 10)
+		for i := 9; i >= 0; i-- {
+			err = l.WriteReserved(ctx, start+memlog.Offset(i), data[i])
+			assert.NilError(t, err)
+		}
+
+		for i, d := range data {
+			r, err := l.Read(ctx, start+memlog.Offset(i))
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Offset, start+memlog.Offset(i))
+			assert.DeepEqual(t, r.Data, d)
+		}
+
+		_, ok, err = l.VerifyContiguous(ctx, 0, 11)
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+
+		// view is not modified
+		_, err = v.Read(ctx, 5)
+		assert.Assert(t, errors.Is(err, memlog.ErrNoRecord))
+
+		err = l.WriteReserved(ctx, start, []byte("again"))
+		assert.Assert(t, errors.Is(err, memlog.ErrNotReserved))
+
+		err = l.WriteReserved(ctx, 0, []byte("first"))
+		assert.Assert(t, errors.Is(err, memlog.ErrNotReserved))
+
+		err = l.WriteReserved(ctx, 12, []byte("future"))
+		assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+	})
+
+	t.Run("purges partially written reservations", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(5))
+		assert.NilError(t, err)
+
+		start, err := l.Reserve(ctx, 5)
+		assert.NilError(t, err)
+
+		err = l.WriteReserved(ctx, start, []byte("data"))
+		assert.NilError(t, err)
+
+		// purges reserved offsets [0-4]
+		for _, d := range memlog.NewTestDataSlice(t, 6) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		err = l.WriteReserved(ctx, start+1, []byte("data"))
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+	})
+
+	t.Run("streams wait for unwritten reserved offsets", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		start, err := l.Reserve(ctx, 2)
+		assert.NilError(t, err)
+
+		stream := l.Stream(ctx, start)
+		batchStream := l.StreamBatch(ctx, start, 2)
+
+		// fill out of order
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			assert.Check(t, l.WriteReserved(ctx, start+1, []byte("second")))
+			time.Sleep(50 * time.Millisecond)
+			assert.Check(t, l.WriteReserved(ctx, start, []byte("first")))
+		}()
+
+		for _, want := range []string{"first", "second"} {
+			r, ok := stream.Next()
+			assert.Assert(t, ok, stream.Err())
+			assert.Equal(t, string(r.Data), want)
+		}
+
+		records, ok := batchStream.Next()
+		assert.Assert(t, ok, batchStream.Err())
+		assert.Equal(t, len(records), 2)
+		assert.Equal(t, string(records[0].Data), "first")
+	})
+
+	t.Run("accounts written reserved offsets", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+		keyFn := func(data []byte) []byte { return data }
+
+		l, err := memlog.New(ctx,
+			memlog.WithClock(c),
+			memlog.WithMaxSegmentSize(10),
+			memlog.WithTimeIndex(time.Second),
+			memlog.WithDedupeKeyFunc(keyFn),
+		)
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("first"))
+		assert.NilError(t, err)
+
+		start, err := l.Reserve(ctx, 1)
+		assert.NilError(t, err)
+
+		c.Add(time.Minute)
+		assert.NilError(t, l.WriteReserved(ctx, start, []byte("reserved")))
+
+		last, ok := l.LastWriteTime(ctx)
+		assert.Assert(t, ok)
+		assert.Equal(t, last, c.Now().UTC())
+		assert.Equal(t, l.Stats(ctx).LastWrite, c.Now().UTC())
+		assert.Equal(t, l.Stats(ctx).Writes, uint64(2))
+
+		offset, err := l.OffsetForTime(ctx, c.Now())
+		assert.NilError(t, err)
+		assert.Equal(t, offset, start)
+
+		// duplicate of the reserved record
+		offset, err = l.Write(ctx, []byte("reserved"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, start)
+	})
+
+	t.Run("last write time skips unwritten reserved offsets", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(2))
+		assert.NilError(t, err)
+
+		_, err = l.Reserve(ctx, 1)
+		assert.NilError(t, err)
+
+		_, ok := l.LastWriteTime(ctx)
+		assert.Assert(t, !ok)
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		want := c.Now().UTC()
+
+		// active segment only contains reserved offsets
+		c.Add(time.Minute)
+		_, err = l.Reserve(ctx, 2)
+		assert.NilError(t, err)
+
+		last, ok := l.LastWriteTime(ctx)
+		assert.Assert(t, ok)
+		assert.Equal(t, last, want)
+	})
+
+	t.Run("oldest time skips unwritten reserved offsets", func(t *testing.T) {
+		ctx := context.Background()
+		c := clock.NewMock()
+		l, err := memlog.New(ctx, memlog.WithClock(c), memlog.WithMaxSegmentSize(10))
+		assert.NilError(t, err)
+
+		_, err = l.Reserve(ctx, 2)
+		assert.NilError(t, err)
+
+		_, ok := l.OldestTime(ctx)
+		assert.Assert(t, !ok)
+
+		c.Add(time.Minute)
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		oldest, ok := l.OldestTime(ctx)
+		assert.Assert(t, ok)
+		assert.Equal(t, oldest, c.Now().UTC())
+	})
+}
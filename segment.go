@@ -11,15 +11,92 @@ var (
 	errFull   = errors.New("segment full")
 )
 
+// SegmentStore stores the records of a log segment, e.g. to back segments with
+// storage other than memory, see WithSegmentStore(). The log guarantees that
+// Append is not called once the store holds the number of records the segment
+// was created with or after Seal was called. The log serializes all calls with
+// its lock, i.e. implementations do not need to be safe for concurrent use
+// unless they are shared across logs.
+type SegmentStore interface {
+	// Append appends r to the store. The data of r must not be retained without
+	// copying if the store modifies it.
+	Append(r Record) error
+	// ReadAt returns the record at index, i.e. the index-th appended record.
+	// The returned record data must not be modified afterwards.
+	ReadAt(index int) (Record, error)
+	// Len returns the number of records in the store
+	Len() int
+	// Cap returns the maximum number of records in the store, which must not be
+	// less than the segment size the store was created with
+	Cap() int
+	// Seal is called when the segment becomes read-only
+	Seal()
+	// Close is called when the segment is purged from the log. Records are not
+	// read from the store afterwards.
+	Close() error
+}
+
+// sliceStore is the default SegmentStore keeping records in memory
+type sliceStore struct {
+	size    int // maximum number of records
+	records []Record
+}
+
+func newSliceStore(size int) SegmentStore {
+	return &sliceStore{
+		size:    size,
+		records: make([]Record, 0, size),
+	}
+}
+
+func (s *sliceStore) Append(r Record) error {
+	if len(s.records) == s.size {
+		return errFull
+	}
+	s.records = append(s.records, r)
+	return nil
+}
+
+func (s *sliceStore) ReadAt(index int) (Record, error) {
+	if index < 0 || index >= len(s.records) {
+		return Record{}, ErrOutOfRange
+	}
+	return s.records[index], nil
+}
+
+func (s *sliceStore) Len() int {
+	return len(s.records)
+}
+
+func (s *sliceStore) Cap() int {
+	return s.size
+}
+
+func (s *sliceStore) Seal() {}
+
+// Close does not release the records as they might be shared with views of the
+// log, see Log.Snapshot()
+func (s *sliceStore) Close() error {
+	return nil
+}
+
 // segment is an append-only data structure for records. Not safe for concurrent
 // use.
 type segment struct {
 	start  Offset // logical start offset
+	size   int    // maximum number of records
 	sealed bool   // false set segment to read-only
-	data   []Record
+	bytes  int    // data bytes of records
+	count  int    // records with data, i.e. not reserved or compacted
+	store  SegmentStore
 }
 
 func newSegment(startOffset Offset, size int) (*segment, error) {
+	return newSegmentWithStore(startOffset, size, newSliceStore)
+}
+
+// newSegmentWithStore creates a segment with a store created by newStore
+func newSegmentWithStore(startOffset Offset, size int, newStore func(size int) SegmentStore) (*segment, error) {
 	if startOffset < 0 {
 		return nil, fmt.Errorf("start offset must not be negative")
 	}
@@ -28,9 +105,19 @@ func newSegment(startOffset Offset, size int) (*segment, error) {
 		return nil, fmt.Errorf("size must be greater than 0")
 	}
 
+	store := newStore(size)
+	if store == nil {
+		return nil, fmt.Errorf("segment store must not be nil")
+	}
+
+	if store.Cap() < size {
+		return nil, fmt.Errorf("segment store capacity %d less than segment size %d", store.Cap(), size)
+	}
+
 	s := segment{
 		start: startOffset,
-		data:  make([]Record, 0, size),
+		size:  size,
+		store: store,
 	}
 
 	return &s, nil
@@ -45,11 +132,17 @@ func (s *segment) write(ctx context.Context, r Record) error {
 		return errSealed
 	}
 
-	if len(s.data) == cap(s.data) {
+	if s.store.Len() == s.size {
 		return errFull
 	}
 
-	s.data = append(s.data, r)
+	if err := s.store.Append(r); err != nil {
+		return fmt.Errorf("append to segment store: %w", err)
+	}
+	s.bytes += len(r.Data)
+	if r.Data != nil {
+		s.count++
+	}
 	return nil
 }
 
@@ -58,28 +151,103 @@ func (s *segment) read(ctx context.Context, offset Offset) (Record, error) {
 		return Record{}, ctx.Err()
 	}
 
-	records := len(s.data)
+	records := s.store.Len()
 	index := offset - s.start
 	if index > Offset(records)-1 || index < 0 {
 		return Record{}, ErrOutOfRange
 	}
 
-	return s.data[index], nil
+	return s.store.ReadAt(int(index))
+}
+
+// records returns the records of the segment in offset order. The records of
+// the default store are returned without copying and must not be modified.
+func (s *segment) records() ([]Record, error) {
+	if ss, ok := s.store.(*sliceStore); ok {
+		return ss.records, nil
+	}
+
+	records := make([]Record, s.store.Len())
+	for i := range records {
+		r, err := s.store.ReadAt(i)
+		if err != nil {
+			return nil, fmt.Errorf("read from segment store: %w", err)
+		}
+		records[i] = r
+	}
+
+	return records, nil
+}
+
+// len returns the number of records in the segment
+func (s *segment) len() int {
+	return s.store.Len()
+}
+
+// fill replaces the record at offset, which must be within the segment. Sealed
+// segments are not protected, i.e. the caller must ensure that the record is a
+// placeholder, e.g. for a reserved offset. Only supported by the default store,
+// see Log.Reserve().
+func (s *segment) fill(offset Offset, r Record) {
+	records := s.store.(*sliceStore).records
+	s.bytes += len(r.Data) - len(records[offset-s.start].Data)
+	if records[offset-s.start].Data == nil && r.Data != nil {
+		s.count++
+	}
+	records[offset-s.start] = r
+}
+
+// trim reallocates the records of the segment to fit the number of records if
+// less than half of the capacity is used, releasing the unused capacity. The
+// segment grows again on subsequent writes. It returns true if the segment was
+// trimmed. Segments with a custom store are not trimmed.
+func (s *segment) trim() bool {
+	ss, ok := s.store.(*sliceStore)
+	if !ok || len(ss.records) > cap(ss.records)/2 {
+		return false
+	}
+
+	data := make([]Record, len(ss.records))
+	copy(data, ss.records)
+	ss.records = data
+	return true
+}
+
+// validate checks that the segment does not exceed its size and that the
+// records have contiguous offsets starting at the segment start offset
+func (s *segment) validate() error {
+	if s.len() > s.size {
+		return fmt.Errorf("segment at offset %d contains %d records, exceeds size %d", s.start, s.len(), s.size)
+	}
+
+	records, err := s.records()
+	if err != nil {
+		return fmt.Errorf("segment at offset %d: %w", s.start, err)
+	}
+
+	for i, r := range records {
+		if want := s.start + Offset(i); r.Metadata.Offset != want {
+			return fmt.Errorf("segment at offset %d contains record with offset %d, want %d", s.start, r.Metadata.Offset, want)
+		}
+	}
+
+	return nil
 }
 
 // seal closes a segment and sets it to read-only
 func (s *segment) seal() {
 	s.sealed = true
+	s.store.Seal()
 }
 
 // currentOffset returns the last write offset starting at segment startOffset.
-// If no write has been performed against the segment before, -1 is returned to
-// denote an empty segment
+// If no write has been performed against the segment before, InvalidOffset is
+// returned to denote an empty segment
 func (s *segment) currentOffset() Offset {
-	if len(s.data) == 0 {
-		return -1
+	if s.store.Len() == 0 {
+		return InvalidOffset
 	}
 
-	offset := s.start + Offset(len(s.data)) - 1
+	offset := s.start + Offset(s.store.Len()) - 1
 	return offset
 }
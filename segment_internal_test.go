@@ -196,7 +196,7 @@ func TestSegment_Write(t *testing.T) {
 		err = s.write(ctx, r)
 		assert.NilError(t, err)
 		assert.Equal(t, s.currentOffset(), start)
-		assert.Equal(t, len(s.data), 1)
+		assert.Equal(t, s.len(), 1)
 
 		res, err := s.read(ctx, start)
 		assert.NilError(t, err)
@@ -250,3 +250,38 @@ func TestSegment_Write(t *testing.T) {
 		assert.DeepEqual(t, testRecords, resRecords)
 	})
 }
+
+func TestSegment_trim(t *testing.T) {
+	ctx := context.Background()
+	s, err := newSegment(0, 10)
+	assert.NilError(t, err)
+
+	write := func(n int) {
+		for i := 0; i < n; i++ {
+			r := Record{Metadata: Header{Offset: s.currentOffset() + 1}, Data: []byte("data")}
+			assert.NilError(t, s.write(ctx, r))
+		}
+	}
+
+	write(6)
+	assert.Assert(t, !s.trim(), "more than half full")
+	assert.Equal(t, cap(s.store.(*sliceStore).records), 10)
+
+	s, err = newSegment(0, 10)
+	assert.NilError(t, err)
+
+	write(3)
+	assert.Assert(t, s.trim())
+	assert.Equal(t, cap(s.store.(*sliceStore).records), 3)
+
+	// grows until full
+	write(7)
+	assert.Equal(t, s.currentOffset(), Offset(9))
+	assert.Assert(t, errors.Is(s.write(ctx, Record{Data: []byte("data")}), errFull))
+
+	for i := 0; i < 10; i++ {
+		r, err := s.read(ctx, Offset(i))
+		assert.NilError(t, err)
+		assert.Equal(t, r.Metadata.Offset, Offset(i))
+	}
+}
@@ -0,0 +1,68 @@
+package sharded
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// bloomFilter is a probabilistic set of keys. Safe for concurrent use.
+type bloomFilter struct {
+	mu     sync.RWMutex
+	bits   []uint64
+	m      uint64 // number of bits
+	hashes uint64 // number of hash functions
+}
+
+// newBloomFilter creates a bloom filter sized for n keys with the specified
+// false positive rate
+func newBloomFilter(n uint, fpRate float64) *bloomFilter {
+	m := math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(n)*math.Ln2))
+
+	bits := uint64(m)
+	return &bloomFilter{
+		bits:   make([]uint64, (bits+63)/64),
+		m:      bits,
+		hashes: uint64(k),
+	}
+}
+
+func (b *bloomFilter) add(key []byte) {
+	h1, h2 := bloomHash(key)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *bloomFilter) mightContain(key []byte) bool {
+	h1, h2 := bloomHash(key)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bloomHash returns two hashes of key for double hashing
+func bloomHash(key []byte) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write(key) // never returns an error
+	sum := h.Sum64()
+
+	h1 := sum & math.MaxUint32
+	h2 := sum>>32 | 1 // odd to cycle through all bits
+	return h1, h2
+}
@@ -0,0 +1,35 @@
+package sharded
+
+import (
+	"strconv"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func Test_bloomFilter(t *testing.T) {
+	const (
+		keys   = 1000
+		fpRate = 0.01
+	)
+
+	b := newBloomFilter(keys, fpRate)
+	for i := 0; i < keys; i++ {
+		b.add([]byte("key-" + strconv.Itoa(i)))
+	}
+
+	// no false negatives
+	for i := 0; i < keys; i++ {
+		assert.Assert(t, b.mightContain([]byte("key-"+strconv.Itoa(i))))
+	}
+
+	var fp int
+	for i := 0; i < keys*10; i++ {
+		if b.mightContain([]byte("other-" + strconv.Itoa(i))) {
+			fp++
+		}
+	}
+
+	// allow for variance
+	assert.Assert(t, float64(fp)/(keys*10) < 3*fpRate, "false positives: %d", fp)
+}
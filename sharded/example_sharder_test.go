@@ -14,7 +14,11 @@ func Example_sharder() {
 	ctx := context.Background()
 
 	keys := []string{"galaxies", "planets"}
-	ks := sharded.NewKeySharder(keys)
+	ks, err := sharded.NewKeySharder(keys)
+	if err != nil {
+		fmt.Printf("create sharder: %v", err)
+		os.Exit(1)
+	}
 
 	opts := []sharded.Option{
 		sharded.WithNumShards(uint(len(keys))), // must be >=len(keys)
@@ -1,6 +1,7 @@
 package sharded
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -10,13 +11,42 @@ import (
 	"github.com/embano1/memlog"
 )
 
+// ErrKeyTooLarge is returned when the key is larger than the configured maximum
+// key size
+var ErrKeyTooLarge = errors.New("key too large")
+
 type config struct {
-	shards uint
+	name        string // label, empty by default
+	shards      uint
+	maxKeySize  int     // bytes, 0 means no limit
+	bloomKeys   uint    // expected keys, 0 means no bloom filter
+	bloomFPRate float64 // bloom filter false positive rate
 
 	// memlog.Log settings
-	startOffset   memlog.Offset
-	segmentSize   int // offsets per segment
-	maxRecordSize int // bytes
+	startOffset     memlog.Offset
+	segmentSize     int            // offsets per segment
+	keySegmentSizes map[string]int // offsets per segment for a key
+	maxRecordSize   int            // bytes
+}
+
+// Config is a read-only view of the configuration of a sharded log
+type Config struct {
+	// Name is the name of the log, empty if not set
+	Name string
+	// Shards is the number of shards in the log
+	Shards uint
+	// MaxKeySize is the maximum key size in bytes, 0 means no limit
+	MaxKeySize int
+	// StartOffset is the start offset of each shard
+	StartOffset memlog.Offset
+	// SegmentSize is the number of offsets per segment in each shard
+	SegmentSize int
+	// KeySegmentSizes is the number of offsets per segment in the shard of a
+	// key specified with WithPerKeySegmentSize()
+	KeySegmentSizes map[string]int
+	// MaxRecordSize is the maximum record data (payload) size in bytes in each
+	// shard
+	MaxRecordSize int
 }
 
 // Log is a sharded log implementation on top of memlog.Log. It uses a
@@ -27,6 +57,7 @@ type Log struct {
 	clock   clock.Clock
 	conf    config
 	shards  []*memlog.Log
+	bloom   *bloomFilter // nil if disabled
 }
 
 // New creates a new sharded log which can be customized with options. If not
@@ -48,16 +79,44 @@ func New(ctx context.Context, options ...Option) (*Log, error) {
 		}
 	}
 
+	if l.conf.bloomKeys > 0 {
+		l.bloom = newBloomFilter(l.conf.bloomKeys, l.conf.bloomFPRate)
+	}
+
 	shards := l.conf.shards
-	l.shards = make([]*memlog.Log, shards)
-	opts := []memlog.Option{
-		memlog.WithClock(l.clock),
-		memlog.WithMaxRecordDataSize(l.conf.maxRecordSize),
-		memlog.WithStartOffset(l.conf.startOffset),
-		memlog.WithMaxSegmentSize(l.conf.segmentSize),
+	segmentSizes := make([]int, shards)
+	for i := range segmentSizes {
+		segmentSizes[i] = l.conf.segmentSize
 	}
 
+	if len(l.conf.keySegmentSizes) > 0 {
+		ks, ok := l.sharder.(*KeySharder)
+		if !ok {
+			return nil, errors.New("per key segment sizes require a KeySharder")
+		}
+
+		for key, size := range l.conf.keySegmentSizes {
+			shard, err := ks.Shard([]byte(key), shards)
+			if err != nil {
+				return nil, fmt.Errorf("get shard for key %q: %w", key, err)
+			}
+			segmentSizes[shard] = size
+		}
+	}
+
+	l.shards = make([]*memlog.Log, shards)
 	for i := 0; i < int(shards); i++ {
+		opts := []memlog.Option{
+			memlog.WithClock(l.clock),
+			memlog.WithMaxRecordDataSize(l.conf.maxRecordSize),
+			memlog.WithStartOffset(l.conf.startOffset),
+			memlog.WithMaxSegmentSize(segmentSizes[i]),
+		}
+
+		if l.conf.name != "" {
+			opts = append(opts, memlog.WithName(fmt.Sprintf("%s#%d", l.conf.name, i)))
+		}
+
 		ml, err := memlog.New(ctx, opts...)
 		if err != nil {
 			return nil, fmt.Errorf("create shard: %w", err)
@@ -68,20 +127,120 @@ func New(ctx context.Context, options ...Option) (*Log, error) {
 	return &l, nil
 }
 
-// Write writes data to the log using the specified key for sharding
-func (l *Log) Write(ctx context.Context, key []byte, data []byte) (memlog.Offset, error) {
+// Config returns the configuration of the log, e.g. for diagnostics
+func (l *Log) Config() Config {
+	var keySizes map[string]int
+	if len(l.conf.keySegmentSizes) > 0 {
+		keySizes = make(map[string]int, len(l.conf.keySegmentSizes))
+		for key, size := range l.conf.keySegmentSizes {
+			keySizes[key] = size
+		}
+	}
+
+	return Config{
+		Name:            l.conf.name,
+		Shards:          l.conf.shards,
+		MaxKeySize:      l.conf.maxKeySize,
+		StartOffset:     l.conf.startOffset,
+		SegmentSize:     l.conf.segmentSize,
+		KeySegmentSizes: keySizes,
+		MaxRecordSize:   l.conf.maxRecordSize,
+	}
+}
+
+// Name returns the name of the log set with WithName(). If no name was set, an
+// empty string is returned.
+func (l *Log) Name() string {
+	return l.conf.name
+}
+
+// ShardStats returns the statistics of each shard in shard order
+func (l *Log) ShardStats(ctx context.Context) []memlog.Stats {
+	stats := make([]memlog.Stats, len(l.shards))
+	for i, shard := range l.shards {
+		stats[i] = shard.Stats(ctx)
+	}
+	return stats
+}
+
+// TotalStats returns the statistics of all shards summed up, i.e. treating the
+// sharded log as a single log. The name is the name of the sharded log and
+// LastWrite is the latest last write of all shards. As offsets are per shard,
+// Earliest and Latest are InvalidOffset and ActiveFillRatio is 0. Note that the
+// statistics of each shard are retrieved independently, i.e. they are not a
+// consistent point-in-time view of the log under concurrent writes.
+func (l *Log) TotalStats(ctx context.Context) memlog.Stats {
+	total := memlog.Stats{
+		Name:     l.conf.name,
+		Earliest: memlog.InvalidOffset,
+		Latest:   memlog.InvalidOffset,
+	}
+	for _, stats := range l.ShardStats(ctx) {
+		total.Records += stats.Records
+		total.Bytes += stats.Bytes
+		total.Purges += stats.Purges
+		total.SegmentsCreated += stats.SegmentsCreated
+		total.SegmentsPurged += stats.SegmentsPurged
+		total.Writes += stats.Writes
+		total.Reads += stats.Reads
+		if stats.LastWrite.After(total.LastWrite) {
+			total.LastWrite = stats.LastWrite
+		}
+	}
+	return total
+}
+
+// shard validates key and returns the shard for key
+func (l *Log) shard(key []byte) (uint, error) {
 	if key == nil {
-		return -1, errors.New("invalid key")
+		return 0, errors.New("invalid key")
+	}
+
+	if l.conf.maxKeySize > 0 && len(key) > l.conf.maxKeySize {
+		return 0, ErrKeyTooLarge
 	}
 
 	shard, err := l.sharder.Shard(key, l.conf.shards)
 	if err != nil {
-		return -1, fmt.Errorf("get shard: %w", err)
+		return 0, fmt.Errorf("get shard: %w", err)
+	}
+
+	return shard, nil
+}
+
+// ShardFor returns the index of the shard the specified key maps to using the
+// configured sharder, e.g. to debug skewed key distributions. Sharder errors are
+// returned.
+func (l *Log) ShardFor(key []byte) (uint, error) {
+	return l.shard(key)
+}
+
+// Shard returns the shard at the specified index, e.g. for per-shard assertions
+// in tests or tooling. An error is returned if index is out of range. Callers
+// must not write to the returned shard directly: records are only readable
+// through the log if they were routed to the shard by the sharder, i.e. written
+// with Write.
+func (l *Log) Shard(index uint) (*memlog.Log, error) {
+	if index >= uint(len(l.shards)) {
+		return nil, fmt.Errorf("shard index %d out of range, log has %d shards", index, len(l.shards))
+	}
+	return l.shards[index], nil
+}
+
+// Write writes data to the log using the specified key for sharding
+func (l *Log) Write(ctx context.Context, key []byte, data []byte) (memlog.Offset, error) {
+	shard, err := l.shard(key)
+	if err != nil {
+		return memlog.InvalidOffset, err
 	}
 
 	offset, err := l.shards[shard].Write(ctx, data)
 	if err != nil {
-		return -1, fmt.Errorf("write to shard: %w", err)
+		return memlog.InvalidOffset, fmt.Errorf("write to shard: %w", err)
+	}
+
+	if l.bloom != nil {
+		l.bloom.add(key)
 	}
 
 	return offset, nil
@@ -90,13 +249,9 @@ func (l *Log) Write(ctx context.Context, key []byte, data []byte) (memlog.Offset
 // Read reads a record from the log at offset using the specified key for shard
 // lookup
 func (l *Log) Read(ctx context.Context, key []byte, offset memlog.Offset) (memlog.Record, error) {
-	if key == nil {
-		return memlog.Record{}, errors.New("invalid key")
-	}
-
-	shard, err := l.sharder.Shard(key, l.conf.shards)
+	shard, err := l.shard(key)
 	if err != nil {
-		return memlog.Record{}, fmt.Errorf("get shard: %w", err)
+		return memlog.Record{}, err
 	}
 
 	r, err := l.shards[shard].Read(ctx, offset)
@@ -106,3 +261,43 @@ func (l *Log) Read(ctx context.Context, key []byte, offset memlog.Offset) (memlo
 
 	return r, nil
 }
+
+// ReadPrefix reads the record at offset for all keys with the specified prefix
+// and returns the records in lexicographical key order. ReadPrefix only works
+// with a KeySharder, otherwise an error is returned. If reading any of the
+// matching keys fails, the error is returned.
+func (l *Log) ReadPrefix(ctx context.Context, prefix []byte, offset memlog.Offset) ([]memlog.Record, error) {
+	ks, ok := l.sharder.(*KeySharder)
+	if !ok {
+		return nil, errors.New("prefix reads require a KeySharder")
+	}
+
+	var records []memlog.Record
+	for _, key := range ks.Keys() {
+		if !bytes.HasPrefix([]byte(key), prefix) {
+			continue
+		}
+
+		r, err := l.Read(ctx, []byte(key), offset)
+		if err != nil {
+			return nil, fmt.Errorf("read key %q: %w", key, err)
+		}
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// MightContain reports whether a record might have been written with the
+// specified key. If false is returned, no record was ever written with key. If
+// true is returned, a record might have been written with key with the false
+// positive rate configured with WithKeyBloom(). Records purged from the log are
+// not removed from the bloom filter, i.e. keys of purged records are still
+// reported. If the bloom filter is not enabled, true is returned.
+func (l *Log) MightContain(key []byte) bool {
+	if l.bloom == nil {
+		return true
+	}
+
+	return l.bloom.mightContain(key)
+}
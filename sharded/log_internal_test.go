@@ -91,6 +91,63 @@ func TestNew(t *testing.T) {
 		}
 	})
 
+	t.Run("fails with invalid max key size", func(t *testing.T) {
+		l, err := New(context.Background(), WithMaxKeySize(0))
+		assert.ErrorContains(t, err, "must be greater than 0")
+		assert.DeepEqual(t, l, (*Log)(nil))
+	})
+
+	t.Run("fails with invalid bloom filter settings", func(t *testing.T) {
+		l, err := New(context.Background(), WithKeyBloom(0, 0.01))
+		assert.ErrorContains(t, err, "expected keys must be greater than 0")
+		assert.DeepEqual(t, l, (*Log)(nil))
+
+		l, err = New(context.Background(), WithKeyBloom(10, 1))
+		assert.ErrorContains(t, err, "must be greater than 0 and less than 1")
+		assert.DeepEqual(t, l, (*Log)(nil))
+	})
+
+	t.Run("auto shards", func(t *testing.T) {
+		testCases := []struct {
+			name       string
+			opts       []Option
+			wantShards uint
+			wantErr    string
+		}{
+			{name: "invalid expected keys", opts: []Option{WithAutoShards(0, 1)}, wantErr: "expected keys must be greater than 0"},
+			{name: "invalid load factor", opts: []Option{WithAutoShards(10, 0)}, wantErr: "load factor must be greater than 0"},
+			{name: "computed shards too small", opts: []Option{WithAutoShards(10, 10)}, wantErr: "must be greater than 1"},
+			{name: "rounds up", opts: []Option{WithAutoShards(25, 10)}, wantShards: 3},
+			{name: "clamped to maximum", opts: []Option{WithAutoShards(1<<20, 1)}, wantShards: MaxAutoShards},
+			{name: "num shards wins", opts: []Option{WithAutoShards(25, 10), WithNumShards(5)}, wantShards: 5},
+			{name: "auto shards wins", opts: []Option{WithNumShards(5), WithAutoShards(25, 10)}, wantShards: 3},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				opts := append([]Option{WithMaxSegmentSize(1)}, tc.opts...)
+				l, err := New(context.Background(), opts...)
+				if tc.wantErr != "" {
+					assert.ErrorContains(t, err, tc.wantErr)
+					assert.DeepEqual(t, l, (*Log)(nil))
+					return
+				}
+
+				assert.NilError(t, err)
+				assert.Equal(t, l.conf.shards, tc.wantShards)
+				assert.Equal(t, len(l.shards), int(tc.wantShards))
+			})
+		}
+	})
+
+	t.Run("derives shard names from log name", func(t *testing.T) {
+		l, err := New(context.Background(), WithName("users"), WithNumShards(2))
+		assert.NilError(t, err)
+		assert.Equal(t, l.Name(), "users")
+		assert.Equal(t, l.shards[0].Name(), "users#0")
+		assert.Equal(t, l.shards[1].Name(), "users#1")
+	})
+
 	t.Run("successfully creates new log with defaults", func(t *testing.T) {
 		l, err := New(context.Background())
 		assert.NilError(t, err)
@@ -98,6 +155,34 @@ func TestNew(t *testing.T) {
 		assert.Assert(t, l.conf.segmentSize == DefaultSegmentSize)
 		assert.Assert(t, l.conf.shards == DefaultShards)
 		assert.Assert(t, l.conf.maxRecordSize == DefaultMaxRecordDataBytes)
+		assert.Assert(t, l.conf.maxKeySize == 0)
 		assert.Assert(t, len(l.shards) == DefaultShards)
 	})
 }
+
+func TestNewKeySharder(t *testing.T) {
+	testCases := []struct {
+		name    string
+		keys    []string
+		wantErr string
+	}{
+		{name: "fails with nil keys", keys: nil, wantErr: "must not be empty"},
+		{name: "fails with empty keys", keys: []string{}, wantErr: "must not be empty"},
+		{name: "fails with duplicate keys", keys: []string{"users", "groups", "users"}, wantErr: `duplicate key "users"`},
+		{name: "succeeds with unique keys", keys: []string{"users", "groups"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ks, err := NewKeySharder(tc.keys)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.Assert(t, ks == nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, ks.Keys(), []string{"groups", "users"})
+		})
+	}
+}
@@ -43,7 +43,7 @@ func TestLog_Read_SingleRecord(t *testing.T) {
 			name:         "read fails with out of range error",
 			clock:        clock.NewMock(),
 			shards:       defaultShards,
-			sharder:      sharded.NewKeySharder([]string{"users"}),
+			sharder:      newKeySharder(t, []string{"users"}),
 			start:        defaultStart,
 			segSize:      defaultSegSize,
 			records:      newTestDataMap(t, 100, "users"),
@@ -57,7 +57,7 @@ func TestLog_Read_SingleRecord(t *testing.T) {
 			name:         "read fails with future offset error",
 			clock:        clock.NewMock(),
 			shards:       defaultShards,
-			sharder:      sharded.NewKeySharder([]string{"users"}),
+			sharder:      newKeySharder(t, []string{"users"}),
 			start:        defaultStart,
 			segSize:      defaultSegSize,
 			records:      newTestDataMap(t, 100, "users"),
@@ -71,7 +71,7 @@ func TestLog_Read_SingleRecord(t *testing.T) {
 			name:         "read fails due to invalid offset",
 			clock:        clock.NewMock(),
 			shards:       defaultShards,
-			sharder:      sharded.NewKeySharder([]string{"users"}),
+			sharder:      newKeySharder(t, []string{"users"}),
 			start:        defaultStart,
 			segSize:      defaultSegSize,
 			records:      newTestDataMap(t, 100, "users"),
@@ -85,7 +85,7 @@ func TestLog_Read_SingleRecord(t *testing.T) {
 			name:         "read fails due to non-existing shard key",
 			clock:        clock.NewMock(),
 			shards:       defaultShards,
-			sharder:      sharded.NewKeySharder([]string{"users"}),
+			sharder:      newKeySharder(t, []string{"users"}),
 			start:        defaultStart,
 			segSize:      defaultSegSize,
 			records:      newTestDataMap(t, 100, "users"),
@@ -99,7 +99,7 @@ func TestLog_Read_SingleRecord(t *testing.T) {
 			name:         "read fails due to key shard count mismatch",
 			clock:        clock.NewMock(),
 			shards:       2, // must be smaller than key count
-			sharder:      sharded.NewKeySharder([]string{"users", "groups", "machines"}),
+			sharder:      newKeySharder(t, []string{"users", "groups", "machines"}),
 			start:        defaultStart,
 			segSize:      defaultSegSize,
 			records:      newTestDataMap(t, 100, "users", "groups", "machines"),
@@ -113,7 +113,7 @@ func TestLog_Read_SingleRecord(t *testing.T) {
 			name:         "read succeeds, one key, start offset 0, read offset 0",
 			clock:        clock.NewMock(),
 			shards:       defaultShards,
-			sharder:      sharded.NewKeySharder([]string{"users"}),
+			sharder:      newKeySharder(t, []string{"users"}),
 			start:        defaultStart,
 			segSize:      defaultSegSize,
 			records:      newTestDataMap(t, 10, "users"),
@@ -127,7 +127,7 @@ func TestLog_Read_SingleRecord(t *testing.T) {
 			name:         "read succeeds, one key, start offset 100, read offset 100",
 			clock:        clock.NewMock(),
 			shards:       defaultShards,
-			sharder:      sharded.NewKeySharder([]string{"users"}),
+			sharder:      newKeySharder(t, []string{"users"}),
 			start:        100,
 			segSize:      defaultSegSize,
 			records:      newTestDataMap(t, 10, "users"),
@@ -141,7 +141,7 @@ func TestLog_Read_SingleRecord(t *testing.T) {
 			name:         "read succeeds, two keys, read offset 5",
 			clock:        clock.NewMock(),
 			shards:       defaultShards,
-			sharder:      sharded.NewKeySharder([]string{"users", "groups"}),
+			sharder:      newKeySharder(t, []string{"users", "groups"}),
 			start:        defaultStart,
 			segSize:      defaultSegSize,
 			records:      newTestDataMap(t, 10, "users", "groups"),
@@ -206,7 +206,7 @@ func TestLog_Read_AllRecords_Concurrent(t *testing.T) {
 		sharded.WithNumShards(uint(defaultShards)),
 		sharded.WithStartOffset(defaultStart),
 		sharded.WithMaxSegmentSize(defaultSegSize),
-		sharded.WithSharder(sharded.NewKeySharder(keys)),
+		sharded.WithSharder(newKeySharder(t, keys)),
 	}
 	l, err := sharded.New(ctx, opts...)
 	assert.NilError(t, err)
@@ -314,6 +314,278 @@ func TestLog_Read_AllRecords(t *testing.T) {
 	assert.Equal(t, got, want)
 }
 
+func TestLog_ReadPrefix(t *testing.T) {
+	t.Run("fails without KeySharder", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := sharded.New(ctx)
+		assert.NilError(t, err)
+
+		records, err := l.ReadPrefix(ctx, []byte("users"), 0)
+		assert.ErrorContains(t, err, "require a KeySharder")
+		assert.Assert(t, records == nil)
+	})
+
+	t.Run("reads offset from all keys with prefix", func(t *testing.T) {
+		keys := []string{"users/eu", "users/us", "groups/eu"}
+
+		ctx := context.Background()
+		opts := []sharded.Option{
+			sharded.WithNumShards(uint(defaultShards)),
+			sharded.WithClock(clock.NewMock()),
+			sharded.WithSharder(newKeySharder(t, keys)),
+		}
+		l, err := sharded.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		for _, k := range keys {
+			_, err = l.Write(ctx, []byte(k), []byte(k))
+			assert.NilError(t, err)
+		}
+
+		records, err := l.ReadPrefix(ctx, []byte("users/"), 0)
+		assert.NilError(t, err)
+		assert.Equal(t, len(records), 2)
+		assert.Equal(t, string(records[0].Data), "users/eu")
+		assert.Equal(t, string(records[1].Data), "users/us")
+
+		_, err = l.ReadPrefix(ctx, []byte("users/"), 1)
+		assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+	})
+}
+
+func TestLog_MaxKeySize(t *testing.T) {
+	ctx := context.Background()
+	l, err := sharded.New(ctx, sharded.WithMaxKeySize(5))
+	assert.NilError(t, err)
+
+	offset, err := l.Write(ctx, []byte("users"), []byte("data"))
+	assert.NilError(t, err)
+
+	_, err = l.Read(ctx, []byte("users"), offset)
+	assert.NilError(t, err)
+
+	offset, err = l.Write(ctx, []byte("groups"), []byte("data"))
+	assert.Assert(t, errors.Is(err, sharded.ErrKeyTooLarge))
+	assert.Equal(t, offset, memlog.Offset(-1))
+
+	_, err = l.Read(ctx, []byte("groups"), 0)
+	assert.Assert(t, errors.Is(err, sharded.ErrKeyTooLarge))
+}
+
+func TestLog_MightContain(t *testing.T) {
+	t.Run("always true without bloom filter", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := sharded.New(ctx)
+		assert.NilError(t, err)
+		assert.Assert(t, l.MightContain([]byte("users")))
+	})
+
+	t.Run("reports written keys", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := sharded.New(ctx, sharded.WithKeyBloom(100, 0.001))
+		assert.NilError(t, err)
+
+		assert.Assert(t, !l.MightContain([]byte("users")))
+
+		_, err = l.Write(ctx, []byte("users"), []byte("data"))
+		assert.NilError(t, err)
+
+		assert.Assert(t, l.MightContain([]byte("users")))
+		assert.Assert(t, !l.MightContain([]byte("groups")))
+	})
+}
+
+func TestLog_PerKeySegmentSize(t *testing.T) {
+	t.Run("fails without KeySharder", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := sharded.New(ctx, sharded.WithPerKeySegmentSize(map[string]int{"users": 5}))
+		assert.ErrorContains(t, err, "require a KeySharder")
+		assert.Assert(t, l == nil)
+	})
+
+	t.Run("fails with unknown key", func(t *testing.T) {
+		ctx := context.Background()
+		opts := []sharded.Option{
+			sharded.WithSharder(newKeySharder(t, []string{"users"})),
+			sharded.WithPerKeySegmentSize(map[string]int{"groups": 5}),
+		}
+		l, err := sharded.New(ctx, opts...)
+		assert.ErrorContains(t, err, "shard not found")
+		assert.Assert(t, l == nil)
+	})
+
+	t.Run("fails with invalid size", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := sharded.New(ctx, sharded.WithPerKeySegmentSize(map[string]int{"users": 0}))
+		assert.ErrorContains(t, err, "must be greater than 0")
+		assert.Assert(t, l == nil)
+	})
+
+	t.Run("uses segment size per key", func(t *testing.T) {
+		keys := []string{"users", "groups"}
+
+		ctx := context.Background()
+		opts := []sharded.Option{
+			sharded.WithNumShards(uint(len(keys))),
+			sharded.WithMaxSegmentSize(defaultSegSize),
+			sharded.WithSharder(newKeySharder(t, keys)),
+			sharded.WithPerKeySegmentSize(map[string]int{"users": 2}),
+		}
+		l, err := sharded.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		for _, k := range keys {
+			for i := 0; i < defaultSegSize; i++ {
+				_, err = l.Write(ctx, []byte(k), []byte("data"))
+				assert.NilError(t, err)
+			}
+		}
+
+		_, err = l.Read(ctx, []byte("users"), 0)
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+
+		_, err = l.Read(ctx, []byte("groups"), 0)
+		assert.NilError(t, err)
+	})
+}
+
+func TestLog_ShardFor(t *testing.T) {
+	ctx := context.Background()
+	opts := []sharded.Option{
+		sharded.WithNumShards(2),
+		sharded.WithSharder(newKeySharder(t, []string{"users", "groups"})),
+	}
+	l, err := sharded.New(ctx, opts...)
+	assert.NilError(t, err)
+
+	shard, err := l.ShardFor([]byte("users"))
+	assert.NilError(t, err)
+	assert.Equal(t, shard, uint(0))
+
+	shard, err = l.ShardFor([]byte("groups"))
+	assert.NilError(t, err)
+	assert.Equal(t, shard, uint(1))
+
+	_, err = l.ShardFor([]byte("unknown"))
+	assert.ErrorContains(t, err, "shard not found")
+
+	_, err = l.ShardFor(nil)
+	assert.ErrorContains(t, err, "invalid key")
+}
+
+func TestLog_Shard(t *testing.T) {
+	keys := []string{"users", "groups"}
+
+	ctx := context.Background()
+	opts := []sharded.Option{
+		sharded.WithNumShards(uint(len(keys))),
+		sharded.WithSharder(newKeySharder(t, keys)),
+	}
+	l, err := sharded.New(ctx, opts...)
+	assert.NilError(t, err)
+
+	offset, err := l.Write(ctx, []byte("groups"), []byte("admins"))
+	assert.NilError(t, err)
+
+	t.Run("returns shard at index", func(t *testing.T) {
+		shard, err := l.Shard(1)
+		assert.NilError(t, err)
+
+		r, err := shard.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, []byte("admins"))
+
+		shard, err = l.Shard(0)
+		assert.NilError(t, err)
+		earliest, _ := shard.Range(ctx)
+		assert.Equal(t, earliest, memlog.InvalidOffset)
+	})
+
+	t.Run("fails when index out of range", func(t *testing.T) {
+		shard, err := l.Shard(2)
+		assert.ErrorContains(t, err, "out of range")
+		assert.Assert(t, shard == nil)
+	})
+}
+
+func TestLog_TotalStats(t *testing.T) {
+	keys := []string{"users", "groups"}
+
+	ctx := context.Background()
+	c := clock.NewMock()
+	opts := []sharded.Option{
+		sharded.WithClock(c),
+		sharded.WithName("directory"),
+		sharded.WithNumShards(uint(len(keys))),
+		sharded.WithMaxSegmentSize(defaultSegSize),
+		sharded.WithSharder(newKeySharder(t, keys)),
+	}
+	l, err := sharded.New(ctx, opts...)
+	assert.NilError(t, err)
+
+	// purges offsets [0-9] of users
+	for i := 0; i < 2*defaultSegSize+1; i++ {
+		_, err = l.Write(ctx, []byte("users"), []byte("data"))
+		assert.NilError(t, err)
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err = l.Write(ctx, []byte("groups"), []byte("data"))
+		assert.NilError(t, err)
+	}
+
+	now := c.Now().UTC()
+
+	assert.DeepEqual(t, l.ShardStats(ctx), []memlog.Stats{
+		{Name: "directory#0", Records: 11, Bytes: 44, Purges: 1, SegmentsCreated: 3, SegmentsPurged: 1, Writes: 21, Earliest: 10, Latest: 20, ActiveFillRatio: 0.1, LastWrite: now},
+		{Name: "directory#1", Records: 5, Bytes: 20, Purges: 0, SegmentsCreated: 1, Writes: 5, Earliest: 0, Latest: 4, ActiveFillRatio: 0.5, LastWrite: now},
+	})
+
+	assert.DeepEqual(t, l.TotalStats(ctx), memlog.Stats{
+		Name:            "directory",
+		Records:         16,
+		Bytes:           64,
+		Purges:          1,
+		SegmentsCreated: 4,
+		SegmentsPurged:  1,
+		Writes:          26,
+		Earliest:        memlog.InvalidOffset,
+		Latest:          memlog.InvalidOffset,
+		LastWrite:       now,
+	})
+}
+
+func TestLog_Config(t *testing.T) {
+	ctx := context.Background()
+	opts := []sharded.Option{
+		sharded.WithName("users"),
+		sharded.WithNumShards(2),
+		sharded.WithStartOffset(5),
+		sharded.WithMaxSegmentSize(defaultSegSize),
+		sharded.WithMaxRecordDataSize(100),
+		sharded.WithMaxKeySize(10),
+		sharded.WithSharder(newKeySharder(t, []string{"users", "groups"})),
+		sharded.WithPerKeySegmentSize(map[string]int{"users": 2}),
+	}
+	l, err := sharded.New(ctx, opts...)
+	assert.NilError(t, err)
+
+	conf := l.Config()
+	assert.DeepEqual(t, conf, sharded.Config{
+		Name:            "users",
+		Shards:          2,
+		MaxKeySize:      10,
+		StartOffset:     5,
+		SegmentSize:     defaultSegSize,
+		KeySegmentSizes: map[string]int{"users": 2},
+		MaxRecordSize:   100,
+	})
+
+	// read-only view
+	conf.KeySegmentSizes["users"] = 5
+	assert.Equal(t, l.Config().KeySegmentSizes["users"], 2)
+}
+
 func newTestData(t *testing.T, id, key string) []byte {
 	r := map[string]string{
 		"id":     id,
@@ -328,6 +600,15 @@ func newTestData(t *testing.T, id, key string) []byte {
 	return b
 }
 
+func newKeySharder(t *testing.T, keys []string) *sharded.KeySharder {
+	t.Helper()
+
+	ks, err := sharded.NewKeySharder(keys)
+	assert.NilError(t, err)
+
+	return ks
+}
+
 // map of key/records, creates "count" records per key
 func newTestDataMap(t *testing.T, count int, keys ...string) map[string][][]byte {
 	t.Helper()
@@ -2,6 +2,8 @@ package sharded
 
 import (
 	"errors"
+	"fmt"
+	"math"
 
 	"github.com/benbjohnson/clock"
 
@@ -17,6 +19,8 @@ const (
 	DefaultSegmentSize = memlog.DefaultSegmentSize
 	// DefaultMaxRecordDataBytes is the maximum data (payload) size of a record in a shard
 	DefaultMaxRecordDataBytes = memlog.DefaultMaxRecordDataBytes
+	// MaxAutoShards is the maximum number of shards computed by WithAutoShards
+	MaxAutoShards = 1 << 16
 )
 
 // Option customizes a log
@@ -31,6 +35,30 @@ var defaultOptions = []Option{
 	WithStartOffset(DefaultStartOffset),
 }
 
+// WithAutoShards sets the number of shards in a log based on the expected
+// number of distinct keys and the desired number of keys per shard (load
+// factor), i.e. ceil(expectedKeys/loadFactor) clamped to MaxAutoShards. The
+// computed number of shards must be greater than 1. WithAutoShards and
+// WithNumShards override each other, i.e. the last option wins.
+func WithAutoShards(expectedKeys int, loadFactor float64) Option {
+	return func(log *Log) error {
+		if expectedKeys <= 0 {
+			return errors.New("expected keys must be greater than 0")
+		}
+
+		if loadFactor <= 0 {
+			return errors.New("load factor must be greater than 0")
+		}
+
+		shards := math.Ceil(float64(expectedKeys) / loadFactor)
+		if shards > MaxAutoShards {
+			shards = MaxAutoShards
+		}
+
+		return WithNumShards(uint(shards))(log)
+	}
+}
+
 // WithClock uses the specified clock for setting record timestamps
 func WithClock(c clock.Clock) Option {
 	return func(log *Log) error {
@@ -43,6 +71,40 @@ func WithClock(c clock.Clock) Option {
 	}
 }
 
+// WithKeyBloom enables a bloom filter over all keys written to the log, sized
+// for the expected number of distinct keys and the specified false positive
+// rate, see Log.MightContain(). The false positive rate increases when more
+// than expectedKeys distinct keys are written. The false positive rate must be
+// greater than 0 and less than 1.
+func WithKeyBloom(expectedKeys uint, falsePositiveRate float64) Option {
+	return func(log *Log) error {
+		if expectedKeys == 0 {
+			return errors.New("expected keys must be greater than 0")
+		}
+
+		if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+			return errors.New("false positive rate must be greater than 0 and less than 1")
+		}
+
+		log.conf.bloomKeys = expectedKeys
+		log.conf.bloomFPRate = falsePositiveRate
+		return nil
+	}
+}
+
+// WithMaxKeySize sets the maximum key size in bytes accepted by Read and Write.
+// Larger keys are rejected with ErrKeyTooLarge. Must be greater than 0. By
+// default key sizes are not limited.
+func WithMaxKeySize(n int) Option {
+	return func(log *Log) error {
+		if n <= 0 {
+			return errors.New("size must be greater than 0")
+		}
+		log.conf.maxKeySize = n
+		return nil
+	}
+}
+
 // WithMaxRecordDataSize sets the maximum record data (payload) size in bytes in
 // each shard
 func WithMaxRecordDataSize(size int) Option {
@@ -67,6 +129,16 @@ func WithMaxSegmentSize(size int) Option {
 	}
 }
 
+// WithName sets a human-readable name of the log, e.g. to distinguish many
+// logs in a process in error messages and metrics. Each shard is named after
+// the log with the shard index as suffix, e.g. "name#0".
+func WithName(name string) Option {
+	return func(log *Log) error {
+		log.conf.name = name
+		return nil
+	}
+}
+
 // WithNumShards sets the number of shards in a log
 func WithNumShards(n uint) Option {
 	return func(log *Log) error {
@@ -79,6 +151,24 @@ func WithNumShards(n uint) Option {
 	}
 }
 
+// WithPerKeySegmentSize sets the maximum size, i.e. number of offsets, in the
+// shard of each specified key. Shards of keys not specified use the size
+// specified with WithMaxSegmentSize(). Requires a KeySharder. All sizes must be
+// greater than 0.
+func WithPerKeySegmentSize(sizes map[string]int) Option {
+	return func(log *Log) error {
+		keySizes := make(map[string]int, len(sizes))
+		for key, size := range sizes {
+			if size <= 0 {
+				return fmt.Errorf("size for key %q must be greater than 0", key)
+			}
+			keySizes[key] = size
+		}
+		log.conf.keySegmentSizes = keySizes
+		return nil
+	}
+}
+
 // WithSharder uses the specified sharder for key sharding
 func WithSharder(s Sharder) Option {
 	return func(log *Log) error {
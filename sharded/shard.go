@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash"
 	"hash/fnv"
+	"sort"
 	"sync"
 )
 
@@ -60,14 +61,22 @@ type KeySharder struct {
 
 // NewKeySharder creates a new key-based Sharder, assigning a shard to each
 // unique key. The caller must ensure that there are at least len(keys) shards
-// available in the log.
-func NewKeySharder(keys []string) *KeySharder {
+// available in the log. An error is returned if keys is empty or contains
+// duplicates.
+func NewKeySharder(keys []string) (*KeySharder, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keys must not be empty")
+	}
+
 	ks := KeySharder{shards: map[string]uint{}}
 	for shard, key := range keys {
+		if _, ok := ks.shards[key]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
 		ks.shards[key] = uint(shard)
 	}
 
-	return &ks
+	return &ks, nil
 }
 
 // Shard implements Sharder interface
@@ -85,3 +94,17 @@ func (k *KeySharder) Shard(key []byte, shards uint) (uint, error) {
 
 	return 0, errors.New("shard not found")
 }
+
+// Keys returns the keys known to the sharder in lexicographical order
+func (k *KeySharder) Keys() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make([]string, 0, len(k.shards))
+	for key := range k.shards {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,67 @@
+package sharded_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+	"github.com/embano1/memlog/sharded"
+)
+
+func TestLog_Snapshot(t *testing.T) {
+	t.Run("fails on cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		l, err := sharded.New(ctx)
+		assert.NilError(t, err)
+
+		cancel()
+		v, err := l.Snapshot(ctx)
+		assert.Assert(t, errors.Is(err, context.Canceled))
+		assert.Assert(t, v == nil)
+	})
+
+	t.Run("view does not observe later writes and purges", func(t *testing.T) {
+		keys := []string{"users", "groups"}
+
+		ctx := context.Background()
+		opts := []sharded.Option{
+			sharded.WithNumShards(uint(len(keys))),
+			sharded.WithMaxSegmentSize(defaultSegSize),
+			sharded.WithSharder(newKeySharder(t, keys)),
+		}
+		l, err := sharded.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		for _, k := range keys {
+			_, err = l.Write(ctx, []byte(k), []byte(k))
+			assert.NilError(t, err)
+		}
+
+		v, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+
+		// purges offset 0 in users shard
+		for i := 0; i < 2*defaultSegSize; i++ {
+			_, err = l.Write(ctx, []byte("users"), []byte("new"))
+			assert.NilError(t, err)
+		}
+
+		_, err = l.Read(ctx, []byte("users"), 0)
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+
+		for _, k := range keys {
+			r, err := v.Read(ctx, []byte(k), 0)
+			assert.NilError(t, err)
+			assert.Equal(t, string(r.Data), k)
+
+			_, err = v.Read(ctx, []byte(k), 1)
+			assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+		}
+
+		_, err = v.Read(ctx, []byte("machines"), 0)
+		assert.ErrorContains(t, err, "shard not found")
+	})
+}
@@ -0,0 +1,73 @@
+package sharded
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/embano1/memlog"
+)
+
+// KeyedRecord is a record read from a shard in the log
+type KeyedRecord struct {
+	// Shard is the index of the shard the record was read from
+	Shard  uint
+	Record memlog.Record
+}
+
+// StreamAll streams all records from all shards in the log without the need to
+// know the keys used for writing. Records within a shard are delivered in
+// offset order, but there is no ordering guarantee across shards.
+//
+// StreamAll is a snapshot: the offset range of each shard is captured when
+// StreamAll is called and records written afterwards are not streamed. If a
+// record is purged before it could be read, streaming stops and the error is
+// sent on the error channel.
+//
+// The record channel is closed when all shards are drained or an error
+// occurred. At most one error is sent on the error channel, which is closed
+// after the record channel. The caller must drain the record channel or cancel
+// ctx to release resources.
+func (l *Log) StreamAll(ctx context.Context) (<-chan KeyedRecord, <-chan error) {
+	recordCh := make(chan KeyedRecord)
+	errCh := make(chan error, 1)
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	for i, shard := range l.shards {
+		earliest, latest := shard.Range(ctx)
+		if earliest == memlog.InvalidOffset {
+			// empty shard
+			continue
+		}
+
+		index := uint(i)
+		shard := shard
+		eg.Go(func() error {
+			for offset := earliest; offset <= latest; offset++ {
+				r, err := shard.Read(egCtx, offset)
+				if err != nil {
+					return fmt.Errorf("read from shard %d: %w", index, err)
+				}
+
+				select {
+				case recordCh <- KeyedRecord{Shard: index, Record: r}:
+				case <-egCtx.Done():
+					return egCtx.Err()
+				}
+			}
+			return nil
+		})
+	}
+
+	go func() {
+		err := eg.Wait()
+		close(recordCh)
+		if err != nil {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	return recordCh, errCh
+}
@@ -0,0 +1,86 @@
+package sharded_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+	"github.com/embano1/memlog/sharded"
+)
+
+func TestLog_StreamAll(t *testing.T) {
+	t.Run("streams all records from all shards", func(t *testing.T) {
+		keys := []string{"users", "groups", "machines"}
+
+		ctx := context.Background()
+		opts := []sharded.Option{
+			sharded.WithNumShards(uint(defaultShards)),
+			sharded.WithClock(clock.NewMock()),
+			sharded.WithStartOffset(defaultStart),
+			sharded.WithMaxSegmentSize(defaultSegSize),
+			sharded.WithSharder(newKeySharder(t, keys)),
+		}
+		l, err := sharded.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		data := newTestDataMap(t, defaultSegSize, keys...)
+		for k, records := range data {
+			for _, r := range records {
+				_, err := l.Write(ctx, []byte(k), r)
+				assert.NilError(t, err)
+			}
+		}
+
+		recordCh, errCh := l.StreamAll(ctx)
+
+		next := make(map[uint]memlog.Offset)
+		got := 0
+		for r := range recordCh {
+			// in-order per shard
+			assert.Equal(t, r.Record.Metadata.Offset, next[r.Shard])
+			next[r.Shard]++
+			got++
+		}
+
+		assert.NilError(t, <-errCh)
+		assert.Equal(t, got, len(keys)*defaultSegSize)
+		assert.Equal(t, len(next), len(keys))
+	})
+
+	t.Run("empty log closes channels", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := sharded.New(ctx, sharded.WithNumShards(defaultShards))
+		assert.NilError(t, err)
+
+		recordCh, errCh := l.StreamAll(ctx)
+		_, ok := <-recordCh
+		assert.Assert(t, !ok)
+		assert.NilError(t, <-errCh)
+	})
+
+	t.Run("stops on cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		l, err := sharded.New(ctx, sharded.WithNumShards(defaultShards))
+		assert.NilError(t, err)
+
+		for i := 0; i < defaultSegSize; i++ {
+			_, err = l.Write(ctx, []byte("users"), []byte("data"))
+			assert.NilError(t, err)
+		}
+
+		recordCh, errCh := l.StreamAll(ctx)
+		<-recordCh
+		cancel()
+
+		for range recordCh {
+			// drain
+		}
+		assert.Assert(t, errors.Is(<-errCh, context.Canceled))
+	})
+}
@@ -0,0 +1,268 @@
+package memlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReadView is an immutable point-in-time view of a log created with
+// Log.Snapshot(). Reads from a view never observe writes or purges which
+// happened in the log after the view was created.
+//
+// Safe for concurrent use.
+type ReadView struct {
+	history  []*segment // oldest first
+	active   *segment
+	start    Offset              // earliest offset
+	end      Offset              // next write offset at snapshot time
+	reserved map[Offset]struct{} // reserved offsets not written at snapshot time
+	now      time.Time           // log clock time at snapshot time, see WriteTTL()
+	keys     *keyring            // nil if encryption is disabled
+}
+
+// Snapshot returns a read-only view pinned to the offset range of the log at
+// the time of the call. Records in the view are retained even if they are
+// purged from the log afterwards.
+//
+// Safe for concurrent use.
+func (l *Log) Snapshot(ctx context.Context) (*ReadView, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// segments are append-only, so copying the segment headers under the lock
+	// pins the view to the current records
+	active, err := l.viewSegment(l.active)
+	if err != nil {
+		return nil, err
+	}
+
+	v := ReadView{
+		active: active,
+		start:  l.maxFloor(l.active.start),
+		end:    l.offset,
+		now:    l.clock.Now(),
+		keys:   l.keys,
+	}
+
+	for _, h := range l.history {
+		s, err := l.viewSegment(h)
+		if err != nil {
+			return nil, err
+		}
+		v.history = append(v.history, s)
+	}
+
+	if len(l.reserved) > 0 {
+		v.reserved = make(map[Offset]struct{}, len(l.reserved))
+		for offset := range l.reserved {
+			v.reserved[offset] = struct{}{}
+		}
+	}
+
+	if len(v.history) > 0 {
+		v.start = l.maxFloor(v.history[0].start)
+	}
+
+	return &v, nil
+}
+
+// viewSegment returns a sealed in-memory copy of the segment header of s for a
+// view. The records of the default store are shared with s unless s has
+// reserved offsets, in which case they are copied as they are modified when the
+// reserved offsets are written. Records of custom stores are always copied, as
+// the store is closed when s is purged. Must be protected with a lock by the
+// caller.
+func (l *Log) viewSegment(s *segment) (*segment, error) {
+	records, err := s.records()
+	if err != nil {
+		return nil, err
+	}
+
+	// records of custom stores are already copied
+	if _, ok := s.store.(*sliceStore); ok {
+		if l.hasReserved(s) {
+			records = append([]Record(nil), records...)
+		} else {
+			records = records[:len(records):len(records)]
+		}
+	}
+
+	return &segment{
+		start:  s.start,
+		size:   s.size,
+		sealed: true,
+		bytes:  s.bytes,
+		count:  s.count,
+		store: &sliceStore{
+			size:    s.size,
+			records: records,
+		},
+	}, nil
+}
+
+// WriteSnapshot writes all available records of the log as newline-delimited
+// JSON to w, e.g. for durability. Records are written in offset order.
+//
+// WriteSnapshot only holds the read lock while creating a ReadView (see
+// Snapshot), but not during serialization, so concurrent reads and writes are
+// not blocked by a slow writer w. This is safe because records of segments are
+// never modified once written: writes append beyond the records pinned by the
+// view and purges only remove segments from the log, not the records
+// referenced by the view. The records written to w are the records available
+// when WriteSnapshot is called.
+//
+// Safe for concurrent use.
+func (l *Log) WriteSnapshot(ctx context.Context, w io.Writer) error {
+	v, err := l.Snapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+
+	earliest, latest := v.Range(ctx)
+	if earliest == InvalidOffset {
+		return nil
+	}
+
+	enc := json.NewEncoder(w)
+	for offset := earliest; offset <= latest; offset++ {
+		r, err := v.Read(ctx, offset)
+		if err != nil {
+			// unwritten reserved offsets are skipped
+			if errors.Is(err, ErrNoRecord) {
+				continue
+			}
+			return fmt.Errorf("read offset %d: %w", offset, err)
+		}
+
+		if err = enc.Encode(r); err != nil {
+			return fmt.Errorf("write offset %d: %w", offset, err)
+		}
+	}
+
+	return nil
+}
+
+// Range returns the earliest and latest available record offset in the view.
+// If the view is empty, InvalidOffset for both return values is
+// returned.
+func (v *ReadView) Range(_ context.Context) (earliest, latest Offset) {
+	if v.active.currentOffset() == InvalidOffset {
+		return InvalidOffset, InvalidOffset
+	}
+	return v.start, v.active.currentOffset()
+}
+
+// Read reads a record from the view at the specified offset. If an error
+// occurs, an invalid (empty) record and the error is returned.
+func (v *ReadView) Read(ctx context.Context, offset Offset) (Record, error) {
+	if ctx.Err() != nil {
+		return Record{}, ctx.Err()
+	}
+
+	if offset >= v.end {
+		return Record{}, ErrFutureOffset
+	}
+
+	if offset < v.start {
+		return Record{}, ErrOutOfRange
+	}
+
+	s, err := v.segment(offset)
+	if err != nil {
+		return Record{}, err
+	}
+
+	if _, ok := v.reserved[offset]; ok {
+		return Record{}, ErrNoRecord
+	}
+
+	r, err := s.read(ctx, offset)
+	if err != nil {
+		return Record{}, err
+	}
+
+	// written records always have data
+	if r.Data == nil {
+		return Record{}, ErrCompacted
+	}
+
+	if r.expired(v.now) {
+		return Record{}, errExpired
+	}
+
+	if r.Metadata.ext().Chunk > 0 {
+		return Record{}, ErrChunkContinuation
+	}
+
+	if r, err = decrypt(v.keys, r); err != nil {
+		return Record{}, err
+	}
+
+	if r.Metadata.ext().Chunks > 1 {
+		r, err = assemble(r, func(offset Offset) (Record, error) {
+			s, err := v.segment(offset)
+			if err != nil {
+				return Record{}, err
+			}
+
+			c, err := s.read(ctx, offset)
+			if err != nil {
+				return Record{}, err
+			}
+			return decrypt(v.keys, c)
+		})
+		if err != nil {
+			return Record{}, err
+		}
+	}
+
+	return r.deepCopy(), nil
+}
+
+// segment returns the segment of the view containing offset
+func (v *ReadView) segment(offset Offset) (*segment, error) {
+	if offset >= v.active.start {
+		return v.active, nil
+	}
+
+	for _, h := range v.history {
+		if offset >= h.start && offset <= h.currentOffset() {
+			return h, nil
+		}
+	}
+
+	return nil, ErrOutOfRange
+}
+
+// ReadBatch reads multiple records from the view into batch starting at the
+// specified offset. The number of records read into batch and the error, if
+// any, is returned. See Log.ReadBatch() for the semantics.
+func (v *ReadView) ReadBatch(ctx context.Context, offset Offset, batch []Record) (int, error) {
+	for i := 0; i < len(batch); i++ {
+		r, err := v.Read(ctx, offset)
+		for skippable(err) {
+			offset++
+			r, err = v.Read(ctx, offset)
+		}
+		if err != nil {
+			// invalid start offset or empty view
+			if errors.Is(err, ErrOutOfRange) {
+				return 0, err
+			}
+
+			return i, err
+		}
+		batch[i] = r
+		offset++
+	}
+
+	return len(batch), nil
+}
@@ -0,0 +1,149 @@
+package memlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestLog_Snapshot(t *testing.T) {
+	t.Run("fails on cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		cancel()
+		v, err := l.Snapshot(ctx)
+		assert.Assert(t, errors.Is(err, context.Canceled))
+		assert.Assert(t, v == nil)
+	})
+
+	t.Run("empty log returns empty view", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		v, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+
+		earliest, latest := v.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(-1))
+		assert.Equal(t, latest, memlog.Offset(-1))
+
+		_, err = v.Read(ctx, 0)
+		assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+	})
+
+	t.Run("view does not observe later writes and purges", func(t *testing.T) {
+		const segSize = 10
+
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithClock(clock.NewMock()), memlog.WithMaxSegmentSize(segSize))
+		assert.NilError(t, err)
+
+		// 15 records: history [0-9], active [10-14]
+		for _, d := range memlog.NewTestDataSlice(t, 15) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		v, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+
+		// purges offsets [0-9] from the log
+		for _, d := range memlog.NewTestDataSlice(t, 10) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		_, err = l.Read(ctx, 0)
+		assert.Assert(t, errors.Is(err, memlog.ErrOutOfRange))
+
+		earliest, latest := v.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(0))
+		assert.Equal(t, latest, memlog.Offset(14))
+
+		batch := make([]memlog.Record, 20)
+		count, err := v.ReadBatch(ctx, 0, batch)
+		assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+		assert.Equal(t, count, 15)
+		for i := 0; i < count; i++ {
+			assert.Equal(t, batch[i].Metadata.Offset, memlog.Offset(i))
+		}
+
+		_, err = v.Read(ctx, 15)
+		assert.Assert(t, errors.Is(err, memlog.ErrFutureOffset))
+	})
+}
+
+// blockingWriter blocks the first write until release is closed
+type blockingWriter struct {
+	buf     bytes.Buffer
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+		<-w.release
+	})
+	return w.buf.Write(p)
+}
+
+func TestLog_WriteSnapshot(t *testing.T) {
+	ctx := context.Background()
+	l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10))
+	assert.NilError(t, err)
+
+	data := memlog.NewTestDataSlice(t, 15)
+	for _, d := range data {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	w := blockingWriter{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- l.WriteSnapshot(ctx, &w)
+	}()
+
+	// writes and purges proceed during serialization
+	<-w.started
+	for _, d := range memlog.NewTestDataSlice(t, 20) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+	earliest, _ := l.Range(ctx)
+	assert.Equal(t, earliest, memlog.Offset(20))
+
+	close(w.release)
+	assert.NilError(t, <-errCh)
+
+	var records []memlog.Record
+	dec := json.NewDecoder(&w.buf)
+	for dec.More() {
+		var r memlog.Record
+		assert.NilError(t, dec.Decode(&r))
+		records = append(records, r)
+	}
+
+	assert.Equal(t, len(records), len(data))
+	for i, r := range records {
+		assert.Equal(t, r.Metadata.Offset, memlog.Offset(i))
+		assert.DeepEqual(t, r.Data, data[i])
+	}
+}
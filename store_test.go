@@ -0,0 +1,195 @@
+package memlog_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+// fakeStore is a SegmentStore test double recording calls
+type fakeStore struct {
+	size      int
+	records   []memlog.Record
+	sealed    bool
+	closed    bool
+	appendErr error
+}
+
+func (s *fakeStore) Append(r memlog.Record) error {
+	if s.appendErr != nil {
+		return s.appendErr
+	}
+	s.records = append(s.records, r)
+	return nil
+}
+
+func (s *fakeStore) ReadAt(index int) (memlog.Record, error) {
+	if s.closed {
+		return memlog.Record{}, errors.New("store closed")
+	}
+	return s.records[index], nil
+}
+
+func (s *fakeStore) Len() int {
+	return len(s.records)
+}
+
+func (s *fakeStore) Cap() int {
+	return s.size
+}
+
+func (s *fakeStore) Seal() {
+	s.sealed = true
+}
+
+func (s *fakeStore) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestLog_WithSegmentStore(t *testing.T) {
+	t.Run("writes and reads through custom stores", func(t *testing.T) {
+		ctx := context.Background()
+
+		var stores []*fakeStore
+		newStore := func(size int) memlog.SegmentStore {
+			s := &fakeStore{size: size}
+			stores = append(stores, s)
+			return s
+		}
+
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10), memlog.WithSegmentStore(newStore))
+		assert.NilError(t, err)
+
+		// purges offsets [0-9]
+		for i := 0; i < 25; i++ {
+			_, err = l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+		}
+
+		assert.Equal(t, len(stores), 3)
+		assert.Assert(t, stores[0].sealed && stores[0].closed)
+		assert.Assert(t, stores[1].sealed && !stores[1].closed)
+		assert.Assert(t, !stores[2].sealed && !stores[2].closed)
+		assert.NilError(t, l.Validate())
+
+		stats := l.Stats(ctx)
+		assert.Equal(t, stats.Records, 15)
+		assert.Equal(t, stats.Bytes, 60)
+
+		for offset := memlog.Offset(10); offset < 25; offset++ {
+			r, err := l.Read(ctx, offset)
+			assert.NilError(t, err)
+			assert.Equal(t, r.Metadata.Offset, offset)
+		}
+
+		_, err = l.Read(ctx, 9)
+		assert.ErrorIs(t, err, memlog.ErrOutOfRange)
+
+		// snapshots copy records of custom stores
+		v, err := l.Snapshot(ctx)
+		assert.NilError(t, err)
+
+		// purges offsets [10-19]
+		for i := 0; i < 6; i++ {
+			_, err = l.Write(ctx, []byte("data"))
+			assert.NilError(t, err)
+		}
+		assert.Assert(t, stores[1].closed)
+
+		r, err := v.Read(ctx, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, r.Metadata.Offset, memlog.Offset(10))
+	})
+
+	t.Run("returns append error", func(t *testing.T) {
+		ctx := context.Background()
+		appendErr := errors.New("disk full")
+
+		store := &fakeStore{size: 10}
+		l, err := memlog.New(ctx, memlog.WithMaxSegmentSize(10), memlog.WithSegmentStore(func(int) memlog.SegmentStore {
+			return store
+		}))
+		assert.NilError(t, err)
+
+		store.appendErr = appendErr
+		offset, err := l.Write(ctx, []byte("data"))
+		assert.ErrorIs(t, err, appendErr)
+		assert.Equal(t, offset, memlog.InvalidOffset)
+
+		store.appendErr = nil
+		offset, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(0))
+	})
+
+	t.Run("fails with invalid store", func(t *testing.T) {
+		ctx := context.Background()
+
+		l, err := memlog.New(ctx, memlog.WithSegmentStore(func(int) memlog.SegmentStore {
+			return nil
+		}))
+		assert.ErrorContains(t, err, "segment store must not be nil")
+		assert.Assert(t, l == nil)
+
+		l, err = memlog.New(ctx, memlog.WithMaxSegmentSize(10), memlog.WithSegmentStore(func(int) memlog.SegmentStore {
+			return &fakeStore{size: 5}
+		}))
+		assert.ErrorContains(t, err, "capacity 5 less than segment size 10")
+		assert.Assert(t, l == nil)
+	})
+
+	t.Run("fails to reserve offsets", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx, memlog.WithSegmentStore(func(size int) memlog.SegmentStore {
+			return &fakeStore{size: size}
+		}))
+		assert.NilError(t, err)
+
+		_, err = l.Reserve(ctx, 1)
+		assert.ErrorContains(t, err, "require the default segment store")
+	})
+}
+
+func TestLog_WithChecksumVerification(t *testing.T) {
+	ctx := context.Background()
+
+	var store *fakeStore
+	newStore := func(size int) memlog.SegmentStore {
+		store = &fakeStore{size: size}
+		return store
+	}
+
+	l, err := memlog.New(ctx, memlog.WithSegmentStore(newStore), memlog.WithChecksumVerification())
+	assert.NilError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+	}
+
+	r, err := l.Read(ctx, 0)
+	assert.NilError(t, err)
+	assert.Assert(t, r.Metadata.Checksum != 0)
+
+	// corrupt record in store
+	store.records[0].Data = []byte("dada")
+
+	_, err = l.Read(ctx, 0)
+	assert.ErrorIs(t, err, memlog.ErrChecksumMismatch)
+
+	batch := make([]memlog.Record, 2)
+	count, err := l.ReadBatch(ctx, 0, batch)
+	assert.ErrorIs(t, err, memlog.ErrChecksumMismatch)
+	assert.Equal(t, count, 0)
+
+	_, err = l.ReadRangeBytes(ctx, 0, 1)
+	assert.ErrorIs(t, err, memlog.ErrChecksumMismatch)
+
+	_, err = l.Read(ctx, 1)
+	assert.NilError(t, err)
+}
@@ -16,6 +16,9 @@ type Stream struct {
 	ctx      context.Context
 	log      *Log
 	position Offset
+	end      Offset               // last offset to stream, InvalidOffset if unbounded
+	next     time.Time            // earliest delivery of the next record if rate limited
+	sink     func(r Record) error // nil if not teed
 	done     bool
 	err      error
 }
@@ -24,6 +27,13 @@ type Stream struct {
 // has not stopped, otherwise ok is false and any subsequent calls return an
 // invalid record and false.
 //
+// If the log was created with WithStreamRateLimit(), Next blocks until the next
+// record can be delivered within the rate limit. At an offset reserved with
+// Log.Reserve(), Next blocks until the reserved offset is written.
+//
+// If the log was closed with Log.Close(), the iterator stops and Err() returns
+// ErrClosed.
+//
 // The caller must consult Err() which error caused stopping the error.
 func (s *Stream) Next() (r Record, ok bool) {
 	for {
@@ -37,11 +47,30 @@ func (s *Stream) Next() (r Record, ok bool) {
 			return Record{}, false
 		}
 
+		// subscribe before checking to not miss close
+		changed := s.log.subscribe()
+		if s.log.isClosed() {
+			s.err = ErrClosed
+			s.done = true
+			return Record{}, false
+		}
+
 		r, err := s.log.Read(s.ctx, s.position)
 		if err != nil {
-			if errors.Is(err, ErrFutureOffset) {
-				// back off and continue polling
-				time.Sleep(streamBackoffInterval)
+			// skip records removed by compaction or expired
+			if skippable(err) {
+				if s.end != InvalidOffset && s.position >= s.end {
+					s.done = true
+					return Record{}, false
+				}
+				s.position++
+				continue
+			}
+
+			// wait for the end of the log or an unwritten reserved offset
+			// to be written
+			if isEndOfLog(err) || errors.Is(err, ErrNoRecord) {
+				backoff(s.ctx, changed)
 				continue
 			}
 
@@ -50,20 +79,101 @@ func (s *Stream) Next() (r Record, ok bool) {
 			return Record{}, false
 		}
 
+		if !s.pace() {
+			s.err = s.ctx.Err()
+			s.done = true
+			return Record{}, false
+		}
+
+		if s.sink != nil {
+			if err := s.sink(r); err != nil {
+				s.err = err
+				s.done = true
+				return Record{}, false
+			}
+		}
+
 		s.position = r.Metadata.Offset + 1
+		if s.end != InvalidOffset && r.Metadata.Offset >= s.end {
+			s.done = true
+		}
 		return r, true
 	}
 }
 
+// backoff blocks until the log was modified, ctx is cancelled or the stream
+// backoff interval has passed
+func backoff(ctx context.Context, changed <-chan struct{}) {
+	select {
+	case <-changed:
+	case <-ctx.Done():
+	case <-time.After(streamBackoffInterval):
+	}
+}
+
+// pace blocks until the next record can be delivered within the stream rate
+// limit using the clock of the log. It returns false if the context was
+// cancelled while waiting.
+func (s *Stream) pace() bool {
+	rate := s.log.conf.streamRate
+	if rate == 0 {
+		return true
+	}
+
+	now := s.log.clock.Now()
+	if wait := s.next.Sub(now); wait > 0 {
+		select {
+		case <-s.log.clock.After(wait):
+		case <-s.ctx.Done():
+			return false
+		}
+		now = s.next
+	}
+
+	s.next = now.Add(time.Second / time.Duration(rate))
+	return true
+}
+
 // Err returns the first error that has ocurred during streaming. This method
 // should be called to inspect the error that caused stopping the iterator.
 func (s *Stream) Err() error {
 	return s.err
 }
 
+// TeeStream returns a stream iterator which passes each record returned by
+// Next() to sink before returning it, e.g. to write streamed records to an audit
+// log. If sink returns an error, the stream is stopped and Err() returns the
+// error. Streams can be teed multiple times, in which case the sinks are called
+// in the order they were added. sink must not be nil and must not modify the
+// record.
+//
+// The returned stream iterator continues at the position of s and must only be
+// used within the same goroutine. s must not be used afterwards.
+func TeeStream(s Stream, sink func(r Record) error) Stream {
+	if sink == nil {
+		s.err = errors.New("sink must not be nil")
+		s.done = true
+		return s
+	}
+
+	if prev := s.sink; prev != nil {
+		s.sink = func(r Record) error {
+			if err := prev(r); err != nil {
+				return err
+			}
+			return sink(r)
+		}
+		return s
+	}
+
+	s.sink = sink
+	return s
+}
+
 // Stream returns a stream iterator to stream records, starting at the given
 // start offset. If the start offset is in the future, stream will continuously
-// poll until this offset is written.
+// poll until this offset is written. The start offset might be OffsetEarliest
+// or OffsetLatest, which are resolved when Stream is called.
 //
 // Use Stream.Next() to read from the stream. See the example for how to use
 // this API.
@@ -73,6 +183,165 @@ func (l *Log) Stream(ctx context.Context, start Offset) Stream {
 	return Stream{
 		ctx:      ctx,
 		log:      l,
-		position: start,
+		position: l.resolveStart(start),
+		end:      InvalidOffset,
+	}
+}
+
+// StreamWithCheckpoint returns a stream iterator like Stream, which calls save
+// every n records with the offset of the last processed record, e.g. to
+// persist the progress of a consumer. A record is considered processed when
+// Next() is called again, i.e. save is called by Next() before the record
+// following the n-th record is returned. A consumer resumes with the saved
+// offset plus one and might receive up to n records again (at-least-once
+// delivery). If save returns an error, the stream is stopped and Err() returns
+// the error. n must be greater than 0 and save must not be nil.
+//
+// The returned stream iterator must only be used within the same goroutine.
+func (l *Log) StreamWithCheckpoint(ctx context.Context, start Offset, n int, save func(offset Offset) error) Stream {
+	s := l.Stream(ctx, start)
+
+	switch {
+	case n <= 0:
+		s.err = errors.New("checkpoint interval must be greater than 0")
+		s.done = true
+		return s
+	case save == nil:
+		s.err = errors.New("save function must not be nil")
+		s.done = true
+		return s
+	}
+
+	var delivered int
+	return TeeStream(s, func(r Record) error {
+		if delivered > 0 && delivered%n == 0 {
+			// records are streamed in offset order
+			if err := save(r.Metadata.Offset - 1); err != nil {
+				return err
+			}
+		}
+		delivered++
+		return nil
+	})
+}
+
+// StreamUntil returns a stream iterator like Stream, which stops after the
+// record at the given end offset was delivered. Err() returns nil in this case.
+// If end is in the future, the stream continuously polls until end is written.
+// If start is greater than end or end is negative, the stream is stopped and
+// Stream.Err() returns the error.
+//
+// The returned stream iterator must only be used within the same goroutine.
+func (l *Log) StreamUntil(ctx context.Context, start, end Offset) Stream {
+	s := Stream{
+		ctx:      ctx,
+		log:      l,
+		position: l.resolveStart(start),
+		end:      end,
+	}
+
+	switch {
+	case end < 0:
+		s.err = errors.New("end offset must not be negative")
+		s.done = true
+	case s.position > end:
+		s.err = errors.New("start offset must not be greater than end offset")
+		s.done = true
 	}
+
+	return s
+}
+
+// BatchStream is an iterator to stream records in order from a log in batches.
+// It must only be used within the same goroutine.
+type BatchStream struct {
+	ctx      context.Context
+	log      *Log
+	position Offset
+	size     int
+	done     bool
+	err      error
+}
+
+// Next blocks until at least one Record is available and returns up to size
+// records which are immediately available, i.e. a batch ends before an
+// unwritten reserved offset, see Log.Reserve(), and Next blocks until the
+// offset is written. ok is true if the iterator has not
+// stopped, otherwise ok is false and any subsequent calls return a nil batch
+// and false.
+//
+// If the log was closed with Log.Close(), the iterator stops and Err() returns
+// ErrClosed.
+//
+// The caller must consult Err() which error caused stopping the iterator.
+func (s *BatchStream) Next() (records []Record, ok bool) {
+	for {
+		if s.done {
+			return nil, false
+		}
+
+		if s.ctx.Err() != nil {
+			s.err = s.ctx.Err()
+			s.done = true
+			return nil, false
+		}
+
+		// subscribe before checking to not miss close
+		changed := s.log.subscribe()
+		if s.log.isClosed() {
+			s.err = ErrClosed
+			s.done = true
+			return nil, false
+		}
+
+		batch := make([]Record, s.size)
+		count, err := s.log.ReadBatch(s.ctx, s.position, batch)
+		if count > 0 {
+			s.position = batch[count-1].Metadata.Offset + 1
+			return batch[:count], true
+		}
+
+		if err != nil {
+			// wait for the end of the log or an unwritten reserved offset
+			// to be written
+			if isEndOfLog(err) || errors.Is(err, ErrNoRecord) {
+				backoff(s.ctx, changed)
+				continue
+			}
+
+			s.err = err
+			s.done = true
+			return nil, false
+		}
+	}
+}
+
+// Err returns the first error that has ocurred during streaming. This method
+// should be called to inspect the error that caused stopping the iterator.
+func (s *BatchStream) Err() error {
+	return s.err
+}
+
+// StreamBatch returns a stream iterator to stream records in batches of up to
+// size records, starting at the given start offset. If the start offset is in
+// the future, stream will continuously poll until this offset is written.
+//
+// Use BatchStream.Next() to read from the stream. If size is not greater than
+// 0, the stream is stopped and BatchStream.Err() returns the error.
+//
+// The returned stream iterator must only be used within the same goroutine.
+func (l *Log) StreamBatch(ctx context.Context, start Offset, size int) BatchStream {
+	s := BatchStream{
+		ctx:      ctx,
+		log:      l,
+		position: l.resolveStart(start),
+		size:     size,
+	}
+
+	if size <= 0 {
+		s.err = errors.New("batch size must be greater than 0")
+		s.done = true
+	}
+
+	return s
 }
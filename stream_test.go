@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"gotest.tools/v3/assert"
 )
 
@@ -330,3 +331,412 @@ func TestLog_Stream(t *testing.T) {
 		assert.Equal(t, s2Counter, 5)
 	})
 }
+
+func TestLog_Stream_RateLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := clock.NewMock()
+	l, err := New(ctx, WithClock(c), WithStreamRateLimit(10))
+	assert.NilError(t, err)
+
+	for _, d := range NewTestDataSlice(t, 5) {
+		_, err = l.Write(ctx, d)
+		assert.NilError(t, err)
+	}
+
+	delivered := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		stream := l.Stream(ctx, 0)
+		for ; delivered < 5; delivered++ {
+			if _, ok := stream.Next(); !ok {
+				return
+			}
+		}
+	}()
+
+	// advance clock until all records are delivered
+	start := c.Now()
+	func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				c.Add(10 * time.Millisecond)
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	assert.Equal(t, delivered, 5)
+	// first record is delivered immediately
+	assert.Assert(t, c.Now().Sub(start) >= 400*time.Millisecond)
+}
+
+func TestLog_StreamBatch(t *testing.T) {
+	t.Run("fails with invalid batch size", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		stream := l.StreamBatch(ctx, 0, 0)
+		records, ok := stream.Next()
+		assert.Assert(t, !ok)
+		assert.Assert(t, records == nil)
+		assert.ErrorContains(t, stream.Err(), "must be greater than 0")
+	})
+
+	t.Run("returns out of range error", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx, WithStartOffset(10))
+		assert.NilError(t, err)
+
+		stream := l.StreamBatch(ctx, 0, 5)
+		_, ok := stream.Next()
+		assert.Assert(t, !ok)
+		assert.Assert(t, errors.Is(stream.Err(), ErrOutOfRange))
+	})
+
+	t.Run("streams available records in batches then blocks for new records", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		l, err := New(ctx, WithMaxSegmentSize(20))
+		assert.NilError(t, err)
+
+		for _, d := range NewTestDataSlice(t, 7) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		stream := l.StreamBatch(ctx, 0, 5)
+
+		records, ok := stream.Next()
+		assert.Assert(t, ok)
+		assert.Equal(t, len(records), 5)
+		assert.Equal(t, records[0].Metadata.Offset, Offset(0))
+
+		records, ok = stream.Next()
+		assert.Assert(t, ok)
+		assert.Equal(t, len(records), 2)
+		assert.Equal(t, records[0].Metadata.Offset, Offset(5))
+
+		go func() {
+			time.Sleep(streamBackoffInterval * 3)
+			_, writeErr := l.Write(ctx, []byte("data"))
+			assert.Check(t, writeErr)
+		}()
+
+		records, ok = stream.Next()
+		assert.Assert(t, ok)
+		assert.Equal(t, len(records), 1)
+		assert.Equal(t, records[0].Metadata.Offset, Offset(7))
+
+		cancel()
+		_, ok = stream.Next()
+		assert.Assert(t, !ok)
+		assert.Assert(t, errors.Is(stream.Err(), context.Canceled))
+	})
+
+	t.Run("stops when log is closed while blocked", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		stream := l.StreamBatch(ctx, 0, 5)
+
+		go func() {
+			time.Sleep(streamBackoffInterval * 3)
+			assert.Check(t, l.Close())
+		}()
+
+		_, ok := stream.Next()
+		assert.Assert(t, !ok)
+		assert.Assert(t, errors.Is(stream.Err(), ErrClosed))
+	})
+}
+
+func TestLog_StreamUntil(t *testing.T) {
+	t.Run("fails with invalid offsets", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			start   Offset
+			end     Offset
+			wantErr string
+		}{
+			{name: "start greater than end", start: 10, end: 5, wantErr: "must not be greater than end"},
+			{name: "negative end", start: -10, end: -1, wantErr: "must not be negative"},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				ctx := context.Background()
+				l, err := New(ctx)
+				assert.NilError(t, err)
+
+				stream := l.StreamUntil(ctx, tc.start, tc.end)
+				_, ok := stream.Next()
+				assert.Assert(t, !ok)
+				assert.ErrorContains(t, stream.Err(), tc.wantErr)
+			})
+		}
+	})
+
+	t.Run("stops after end offset", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		for _, d := range NewTestDataSlice(t, 5) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		// end is in the future
+		written := make(chan struct{})
+		go func() {
+			defer close(written)
+			for _, d := range NewTestDataSlice(t, 5) {
+				time.Sleep(streamBackoffInterval)
+				_, writeErr := l.Write(ctx, d)
+				assert.Check(t, writeErr)
+			}
+		}()
+
+		stream := l.StreamUntil(ctx, 2, 7)
+		want := Offset(2)
+		for {
+			r, ok := stream.Next()
+			if !ok {
+				break
+			}
+			assert.Equal(t, r.Metadata.Offset, want)
+			want++
+		}
+
+		assert.NilError(t, stream.Err())
+		assert.Equal(t, want, Offset(8))
+		<-written
+	})
+}
+
+func TestTeeStream(t *testing.T) {
+	t.Run("fails when sink is nil", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		stream := TeeStream(l.Stream(ctx, 0), nil)
+		_, ok := stream.Next()
+		assert.Assert(t, !ok)
+		assert.ErrorContains(t, stream.Err(), "must not be nil")
+	})
+
+	t.Run("passes records to sinks in order", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		for _, d := range NewTestDataSlice(t, 5) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		var calls []string
+		sink := func(name string) func(r Record) error {
+			return func(r Record) error {
+				calls = append(calls, name)
+				return nil
+			}
+		}
+
+		stream := TeeStream(TeeStream(l.StreamUntil(ctx, 0, 4), sink("audit")), sink("metrics"))
+		var read int
+		for {
+			_, ok := stream.Next()
+			if !ok {
+				break
+			}
+			read++
+		}
+
+		assert.NilError(t, stream.Err())
+		assert.Equal(t, read, 5)
+		assert.Equal(t, len(calls), 10)
+		assert.DeepEqual(t, calls[:2], []string{"audit", "metrics"})
+	})
+
+	t.Run("stops with sink error", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		for _, d := range NewTestDataSlice(t, 5) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		sinkErr := errors.New("sink failed")
+		stream := TeeStream(l.Stream(ctx, 0), func(r Record) error {
+			if r.Metadata.Offset == 3 {
+				return sinkErr
+			}
+			return nil
+		})
+
+		var read int
+		for {
+			_, ok := stream.Next()
+			if !ok {
+				break
+			}
+			read++
+		}
+
+		assert.ErrorIs(t, stream.Err(), sinkErr)
+		assert.Equal(t, read, 3)
+	})
+}
+
+func TestLog_Stream_Close(t *testing.T) {
+	t.Run("stops waiting stream with ErrClosed", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		for _, d := range NewTestDataSlice(t, 3) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		stream := l.Stream(ctx, 0)
+		for i := 0; i < 3; i++ {
+			_, ok := stream.Next()
+			assert.Assert(t, ok)
+		}
+
+		// close while Next is waiting for offset 3
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(streamBackoffInterval / 2)
+			assert.Check(t, l.Close())
+		}()
+
+		r, ok := stream.Next()
+		wg.Wait()
+
+		assert.Assert(t, !ok)
+		assert.DeepEqual(t, r, Record{})
+		assert.ErrorIs(t, stream.Err(), ErrClosed)
+		assert.NilError(t, ctx.Err())
+	})
+
+	t.Run("rejects writes after close", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		assert.NilError(t, l.Close())
+		assert.NilError(t, l.Close())
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.ErrorIs(t, err, ErrClosed)
+
+		// records are still readable
+		r, err := l.Read(ctx, 0)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.Data, []byte("data"))
+
+		batch := l.StreamBatch(ctx, 0, 10)
+		_, ok := batch.Next()
+		assert.Assert(t, !ok)
+		assert.ErrorIs(t, batch.Err(), ErrClosed)
+	})
+}
+
+func TestLog_StreamWithCheckpoint(t *testing.T) {
+	t.Run("fails with invalid arguments", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		noop := func(Offset) error { return nil }
+
+		stream := l.StreamWithCheckpoint(ctx, 0, 0, noop)
+		_, ok := stream.Next()
+		assert.Assert(t, !ok)
+		assert.ErrorContains(t, stream.Err(), "must be greater than 0")
+
+		stream = l.StreamWithCheckpoint(ctx, 0, 1, nil)
+		_, ok = stream.Next()
+		assert.Assert(t, !ok)
+		assert.ErrorContains(t, stream.Err(), "must not be nil")
+	})
+
+	t.Run("saves last processed offset every n records", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx, WithStartOffset(10))
+		assert.NilError(t, err)
+
+		for _, d := range NewTestDataSlice(t, 10) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		var saved []Offset
+		stream := l.StreamWithCheckpoint(ctx, 10, 3, func(offset Offset) error {
+			saved = append(saved, offset)
+			return nil
+		})
+
+		for i := 0; i < 10; i++ {
+			r, ok := stream.Next()
+			assert.Assert(t, ok)
+			assert.Equal(t, r.Metadata.Offset, Offset(10+i))
+		}
+
+		assert.NilError(t, stream.Err())
+		assert.DeepEqual(t, saved, []Offset{12, 15, 18})
+	})
+
+	t.Run("stops with save error", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := New(ctx)
+		assert.NilError(t, err)
+
+		for _, d := range NewTestDataSlice(t, 5) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		saveErr := errors.New("save failed")
+		stream := l.StreamWithCheckpoint(ctx, 0, 2, func(Offset) error {
+			return saveErr
+		})
+
+		var read int
+		for {
+			_, ok := stream.Next()
+			if !ok {
+				break
+			}
+			read++
+		}
+
+		assert.ErrorIs(t, stream.Err(), saveErr)
+		assert.Equal(t, read, 2)
+	})
+}
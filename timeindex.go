@@ -0,0 +1,76 @@
+package memlog
+
+import (
+	"time"
+)
+
+// timeIndex is a sparse index mapping fixed-size time buckets to the first
+// offset written in the bucket. Not safe for concurrent use.
+type timeIndex struct {
+	granularity time.Duration
+	buckets     map[int64]Offset
+}
+
+func newTimeIndex(granularity time.Duration) *timeIndex {
+	return &timeIndex{
+		granularity: granularity,
+		buckets:     make(map[int64]Offset),
+	}
+}
+
+// bucket returns the time bucket of t
+func (ti *timeIndex) bucket(t time.Time) int64 {
+	ns := t.UnixNano()
+	b := ns / int64(ti.granularity)
+	if ns < 0 && ns%int64(ti.granularity) != 0 {
+		// round towards negative infinity
+		b--
+	}
+	return b
+}
+
+// add records offset for the bucket of created unless the bucket already
+// exists with a lower offset, e.g. for records written at reserved offsets
+func (ti *timeIndex) add(created time.Time, offset Offset) {
+	b := ti.bucket(created)
+	if existing, ok := ti.buckets[b]; !ok || offset < existing {
+		ti.buckets[b] = offset
+	}
+}
+
+// bounds returns the half-open offset interval [lo,hi) which contains the
+// first record created at or after t, based on the index entries for the
+// bucket of t and the following bucket. Missing entries are returned as
+// InvalidOffset.
+func (ti *timeIndex) bounds(t time.Time) (lo, hi Offset) {
+	lo, hi = InvalidOffset, InvalidOffset
+	b := ti.bucket(t)
+
+	if offset, ok := ti.buckets[b]; ok {
+		lo = offset
+	}
+
+	if offset, ok := ti.buckets[b+1]; ok {
+		hi = offset
+	}
+
+	return lo, hi
+}
+
+// prune removes all buckets pointing to offsets before earliest. The bucket of
+// the earliest record, created at the specified time, is retained and points to
+// earliest.
+func (ti *timeIndex) prune(earliest Offset, created time.Time) {
+	first := ti.bucket(created)
+	for b, offset := range ti.buckets {
+		if offset >= earliest {
+			continue
+		}
+
+		if b == first {
+			ti.buckets[b] = earliest
+			continue
+		}
+		delete(ti.buckets, b)
+	}
+}
@@ -0,0 +1,209 @@
+package memlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// walFile is the name of the write-ahead log file in the persistence directory
+const walFile = "memlog.wal"
+
+// wal is an append-only file of newline-delimited JSON records. Not safe for
+// concurrent use.
+type wal struct {
+	f    *os.File
+	size int64 // bytes of complete records
+	last int64 // size before the last append
+}
+
+// openWAL opens or creates the write-ahead log in dir
+func openWAL(dir string) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, walFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wal{f: f}, nil
+}
+
+// replay calls fn for every record in the write-ahead log in file order. An
+// incomplete trailing record, e.g. from a crash during a write, is discarded.
+func (w *wal) replay(fn func(r Record) error) error {
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	rd := bufio.NewReader(w.f)
+	for {
+		line, err := rd.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// discard incomplete trailing record
+			return w.truncate(w.size)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var r Record
+		if err = json.Unmarshal(bytes.TrimSpace(line), &r); err != nil {
+			return fmt.Errorf("decode record at byte %d: %w", w.size, err)
+		}
+
+		if err = fn(r); err != nil {
+			return err
+		}
+		w.size += int64(len(line))
+	}
+}
+
+// append writes r to the write-ahead log. If the write fails, the log is
+// truncated to the previous record.
+func (w *wal) append(r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	n, err := w.f.Write(append(b, '\n'))
+	if err != nil {
+		return fmt.Errorf("%w (truncate: %v)", err, w.truncate(w.size))
+	}
+
+	w.last = w.size
+	w.size += int64(n)
+	return nil
+}
+
+// rollback removes the record written by the last successful append
+func (w *wal) rollback() error {
+	return w.truncate(w.last)
+}
+
+// truncate truncates the write-ahead log to size bytes
+func (w *wal) truncate(size int64) error {
+	if err := w.f.Truncate(size); err != nil {
+		return err
+	}
+
+	if _, err := w.f.Seek(size, io.SeekStart); err != nil {
+		return err
+	}
+
+	w.size = size
+	return nil
+}
+
+// rewrite replaces the write-ahead log with a file containing only the records
+// of segments in file order. The file is written next to the write-ahead log
+// and renamed, i.e. the write-ahead log is not modified if the rewrite fails.
+func (w *wal) rewrite(segments [][]Record) error {
+	path := w.f.Name()
+	f, err := os.CreateTemp(filepath.Dir(path), walFile+".*")
+	if err != nil {
+		return err
+	}
+
+	size, err := writeRecords(f, segments)
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	// the replaced file is already unlinked
+	_ = w.f.Close()
+	w.f = f
+	w.size = size
+	w.last = size
+	return nil
+}
+
+// writeRecords writes the records of segments to f and syncs f. The number of
+// bytes written is returned.
+func writeRecords(f *os.File, segments [][]Record) (int64, error) {
+	bw := bufio.NewWriter(f)
+	var size int64
+	for _, records := range segments {
+		for _, r := range records {
+			b, err := json.Marshal(r)
+			if err != nil {
+				return 0, err
+			}
+
+			n, err := bw.Write(append(b, '\n'))
+			if err != nil {
+				return 0, err
+			}
+			size += int64(n)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return size, f.Sync()
+}
+
+func (w *wal) close() error {
+	return w.f.Close()
+}
+
+// CompactWAL rewrites the write-ahead log with only the records of the segments
+// retained in memory, see WithPersistence(). The write-ahead log is append-only
+// and otherwise grows with every write, regardless of purged and truncated
+// records. Truncated records in the oldest segment and records removed by
+// compaction, see WithCompaction(), are retained as they are in memory. When
+// the log is restored from a compacted write-ahead log, offsets before the
+// oldest retained record are not available. If persistence is not enabled, an
+// error is returned.
+//
+// CompactWAL holds the write lock while writing the retained records, i.e.
+// writes are blocked until it returns.
+//
+// Safe for concurrent use.
+func (l *Log) CompactWAL(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	if l.wal == nil {
+		return errors.New("persistence is not enabled")
+	}
+
+	segments := make([][]Record, 0, len(l.history)+1)
+	for _, s := range append(l.history[:len(l.history):len(l.history)], l.active) {
+		records, err := s.records()
+		if err != nil {
+			return err
+		}
+		segments = append(segments, records)
+	}
+
+	if err := l.wal.rewrite(segments); err != nil {
+		return fmt.Errorf("rewrite write-ahead log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package memlog_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"gotest.tools/v3/assert"
+
+	"github.com/embano1/memlog"
+)
+
+func TestLog_WithPersistence(t *testing.T) {
+	t.Run("restores log after restart", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+		c := clock.NewMock()
+
+		opts := []memlog.Option{
+			memlog.WithClock(c),
+			memlog.WithStartOffset(5),
+			memlog.WithMaxSegmentSize(10),
+			memlog.WithGlobalSequence(100),
+			memlog.WithPersistence(dir),
+		}
+
+		l, err := memlog.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		// purges offsets [5-14]
+		for _, d := range memlog.NewTestDataSlice(t, 25) {
+			c.Add(time.Second)
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		want := make([]memlog.Record, 15)
+		_, err = l.ReadBatch(ctx, 15, want)
+		assert.NilError(t, err)
+		wantStats := l.Stats(ctx)
+		assert.NilError(t, l.Close())
+
+		// counters are not persisted
+		wantStats.Writes, wantStats.Reads = 0, 0
+
+		restored, err := memlog.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		earliest, latest := restored.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(15))
+		assert.Equal(t, latest, memlog.Offset(29))
+		assert.DeepEqual(t, restored.Stats(ctx), wantStats)
+
+		got := make([]memlog.Record, 15)
+		_, err = restored.ReadBatch(ctx, 15, got)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, want)
+
+		// continues offsets and sequence
+		offset, err := restored.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(30))
+
+		r, err := restored.Read(ctx, offset)
+		assert.NilError(t, err)
+		assert.Equal(t, r.Metadata.Seq, uint64(125))
+		assert.NilError(t, restored.Close())
+	})
+
+	t.Run("does not pass restored segments to purge handler", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		var purged int
+		opts := []memlog.Option{
+			memlog.WithMaxSegmentSize(10),
+			memlog.WithPersistence(dir),
+			memlog.WithPurgeHandler(func(_ context.Context, seg []memlog.Record) {
+				purged += len(seg)
+			}),
+		}
+
+		l, err := memlog.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		// purges offsets [0-9]
+		for _, d := range memlog.NewTestDataSlice(t, 25) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+		assert.Equal(t, purged, 10)
+		assert.NilError(t, l.Close())
+
+		l, err = memlog.New(ctx, opts...)
+		assert.NilError(t, err)
+		assert.Equal(t, purged, 10)
+
+		earliest, _ := l.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(10))
+
+		// purges offsets [10-19]
+		for _, d := range memlog.NewTestDataSlice(t, 6) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+		assert.Equal(t, purged, 20)
+		assert.NilError(t, l.Close())
+	})
+
+	t.Run("discards incomplete trailing record", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		l, err := memlog.New(ctx, memlog.WithPersistence(dir))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.NilError(t, l.Close())
+
+		// simulate crash during write
+		f, err := os.OpenFile(filepath.Join(dir, "memlog.wal"), os.O_APPEND|os.O_WRONLY, 0)
+		assert.NilError(t, err)
+		_, err = f.WriteString(`{"metadata":{"offset":1`)
+		assert.NilError(t, err)
+		assert.NilError(t, f.Close())
+
+		l, err = memlog.New(ctx, memlog.WithPersistence(dir))
+		assert.NilError(t, err)
+
+		offset, err := l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(1))
+		assert.NilError(t, l.Close())
+
+		l, err = memlog.New(ctx, memlog.WithPersistence(dir))
+		assert.NilError(t, err)
+		_, latest := l.Range(ctx)
+		assert.Equal(t, latest, memlog.Offset(1))
+		assert.NilError(t, l.Close())
+	})
+
+	t.Run("restores log from compacted write-ahead log", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		opts := []memlog.Option{
+			memlog.WithMaxSegmentSize(10),
+			memlog.WithPersistence(dir),
+		}
+
+		l, err := memlog.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		// purges offsets [0-19]
+		for _, d := range memlog.NewTestDataSlice(t, 35) {
+			_, err = l.Write(ctx, d)
+			assert.NilError(t, err)
+		}
+
+		before, err := os.Stat(filepath.Join(dir, "memlog.wal"))
+		assert.NilError(t, err)
+		assert.NilError(t, l.CompactWAL(ctx))
+
+		after, err := os.Stat(filepath.Join(dir, "memlog.wal"))
+		assert.NilError(t, err)
+		assert.Assert(t, after.Size() < before.Size())
+
+		// appended to the compacted write-ahead log
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+
+		want := make([]memlog.Record, 16)
+		_, err = l.ReadBatch(ctx, 20, want)
+		assert.NilError(t, err)
+		assert.NilError(t, l.Close())
+
+		restored, err := memlog.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		earliest, latest := restored.Range(ctx)
+		assert.Equal(t, earliest, memlog.Offset(20))
+		assert.Equal(t, latest, memlog.Offset(35))
+
+		got := make([]memlog.Record, 16)
+		_, err = restored.ReadBatch(ctx, 20, got)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, want)
+
+		_, err = restored.Read(ctx, 19)
+		assert.ErrorIs(t, err, memlog.ErrOutOfRange)
+
+		offset, err := restored.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.Equal(t, offset, memlog.Offset(36))
+		assert.NilError(t, restored.Close())
+	})
+
+	t.Run("restores records removed by compaction from compacted write-ahead log", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		opts := []memlog.Option{
+			memlog.WithMaxSegmentSize(5),
+			memlog.WithCompaction(),
+			memlog.WithPersistence(dir),
+		}
+
+		l, err := memlog.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		for i := 0; i < 6; i++ {
+			_, err = l.WriteKey(ctx, []byte("key"), []byte("data"))
+			assert.NilError(t, err)
+		}
+
+		// offsets [0-4] are removed by compaction
+		assert.NilError(t, l.Compact(ctx))
+		assert.NilError(t, l.CompactWAL(ctx))
+		wantStats := l.Stats(ctx)
+		assert.NilError(t, l.Close())
+
+		restored, err := memlog.New(ctx, opts...)
+		assert.NilError(t, err)
+
+		_, err = restored.Read(ctx, 0)
+		assert.ErrorIs(t, err, memlog.ErrCompacted)
+		assert.Equal(t, restored.Stats(ctx).Records, wantStats.Records)
+		assert.Equal(t, restored.Stats(ctx).Bytes, wantStats.Bytes)
+
+		r, err := restored.Read(ctx, 5)
+		assert.NilError(t, err)
+		assert.Equal(t, string(r.Data), "data")
+		assert.NilError(t, restored.Close())
+	})
+
+	t.Run("compaction fails without persistence", func(t *testing.T) {
+		ctx := context.Background()
+		l, err := memlog.New(ctx)
+		assert.NilError(t, err)
+
+		err = l.CompactWAL(ctx)
+		assert.ErrorContains(t, err, "persistence is not enabled")
+	})
+
+	t.Run("fails when start offset changed", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		l, err := memlog.New(ctx, memlog.WithPersistence(dir))
+		assert.NilError(t, err)
+
+		_, err = l.Write(ctx, []byte("data"))
+		assert.NilError(t, err)
+		assert.NilError(t, l.Close())
+
+		l, err = memlog.New(ctx, memlog.WithPersistence(dir), memlog.WithStartOffset(10))
+		assert.ErrorContains(t, err, "record offset 0 does not match next offset 10")
+		assert.Assert(t, l == nil)
+	})
+
+	t.Run("fails on corrupt record", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		err := os.WriteFile(filepath.Join(dir, "memlog.wal"), []byte("not json\n"), 0o644)
+		assert.NilError(t, err)
+
+		l, err := memlog.New(ctx, memlog.WithPersistence(dir))
+		assert.ErrorContains(t, err, "decode record at byte 0")
+		assert.Assert(t, l == nil)
+	})
+}